@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/stapelberg/wsmgr-for-i3/internal/cwd"
+)
+
+type Browser struct {
+	Executable  string   `json:"executable"`
+	Class       string   `json:"class"`         // X11 window class, for recognizing new windows
+	ConfigDir   string   `json:"config_dir"`    // user data directory, relative to ~/.config
+	UserDataDir string   `json:"user_data_dir"` // user data directory (overrides ConfigDir)
+	FlatPak     string   `json:"flatpak"`
+	Snap        string   `json:"snap"`
+	Wrapper     []string `json:"wrapper"` // command to start the browser, instead of Executable
+	Flags       []string `json:"flags"`   // extra command line flags
+	Firefox     bool     `json:"firefox"` // read bookmarks from places.sqlite instead of Bookmarks
+	Profile     string   `json:"-"`       // profile directory or name, empty for the default profile
+	App         bool     `json:"-"`       // open each URL in an app window (--app)
+	Incognito   bool     `json:"-"`       // open windows in incognito mode
+
+	launch      []string // command to start the browser, depending on the location
+	dataDir     string   // resolved user data directory
+	profileDir  string   // resolved profile directory
+	profileName string   // resolved Firefox profile name (for -P), empty for the default profile
+}
+
+var browsers = map[string]Browser{
+	"brave":    {Executable: "brave-browser", Class: "Brave-browser", ConfigDir: "BraveSoftware/Brave-Browser", FlatPak: "com.brave.Browser"},
+	"chrome":   {Executable: "google-chrome", Class: "Google-chrome", ConfigDir: "google-chrome", FlatPak: "com.google.Chrome"},
+	"chromium": {Executable: "chromium-browser", Class: "Chromium", ConfigDir: "chromium", FlatPak: "org.chromium.Chromium", Snap: "chromium"},
+	"edge":     {Executable: "msedge", Class: "Microsoft-edge", ConfigDir: "microsoft-edge", FlatPak: "com.microsoft.Edge"},
+	"firefox":  {Executable: "firefox", Class: "firefox", FlatPak: "org.mozilla.firefox", Snap: "firefox", Firefox: true},
+}
+
+// detectOrder is the order in which the built-in browsers are tried when no
+// -browser flag is specified.
+var detectOrder = []string{"chrome", "chromium", "brave", "edge", "firefox"}
+
+// loadBrowsers returns the built-in browsers, amended by the browsers.json file
+// in ~/.config/wsmgr-for-i3, and the order in which to detect them: browsers
+// defined only in browsers.json (sorted by name), then the built-in browsers.
+//
+// browsers.json maps browser names to definitions. Definitions of built-in
+// browsers only need to contain the fields which should be changed, e.g.:
+//
+//	{
+//	  "vivaldi": {"executable": "vivaldi", "class": "Vivaldi-stable", "config_dir": "vivaldi"},
+//	  "chrome": {"flags": ["--force-dark-mode"]}
+//	}
+func loadBrowsers() (map[string]Browser, []string, error) {
+	result := make(map[string]Browser, len(browsers))
+	for name, b := range browsers {
+		result[name] = b
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	fn := filepath.Join(configDir, "wsmgr-for-i3", "browsers.json")
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, detectOrder, nil
+		}
+		return nil, nil, err
+	}
+	var defs map[string]json.RawMessage
+	if err := json.Unmarshal(b, &defs); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	var order []string
+	for name, def := range defs {
+		b, builtin := result[name]
+		if err := json.Unmarshal(def, &b); err != nil {
+			return nil, nil, fmt.Errorf("%s: browser %q: %v", fn, name, err)
+		}
+		if b.Executable == "" && len(b.Wrapper) == 0 {
+			return nil, nil, fmt.Errorf("%s: browser %q: neither executable nor wrapper specified", fn, name)
+		}
+		// An empty config_dir would make ~/.config itself the user data
+		// directory.
+		if !b.Firefox && b.ConfigDir == "" && b.UserDataDir == "" {
+			return nil, nil, fmt.Errorf("%s: browser %q: neither config_dir nor user_data_dir specified", fn, name)
+		}
+		result[name] = b
+		if !builtin {
+			order = append(order, name)
+		}
+	}
+	sort.Strings(order)
+	return result, append(order, detectOrder...), nil
+}
+
+// detectBrowser returns the name of the first installed browser, i.e. the
+// first browser whose executable is in $PATH or whose data directory exists.
+func detectBrowser(browsers map[string]Browser, order []string) (string, error) {
+	for _, name := range order {
+		b := browsers[name]
+		if b.Executable != "" {
+			if _, err := exec.LookPath(b.Executable); err == nil {
+				return name, nil
+			}
+		}
+		locations, err := b.locations()
+		if err != nil {
+			return "", err
+		}
+		for _, l := range locations {
+			if _, err := os.Stat(l.dir); err == nil {
+				return name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no installed browser found (tried %v), use -browser", order)
+}
+
+// location is a directory in which a browser stores its data (the user data
+// directory for Chromium, the directory containing profiles.ini for Firefox),
+// and the command which starts the browser using this directory.
+type location struct {
+	dir    string
+	launch []string // nil for the browser’s executable
+}
+
+// locations returns the directories in which the browser can store its data:
+// the regular location, the flatpak location and the snap location.
+func (b *Browser) locations() ([]location, error) {
+	if b.UserDataDir != "" {
+		dir, err := cwd.Expand(b.UserDataDir)
+		if err != nil {
+			return nil, err
+		}
+		return []location{{dir: dir}}, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	// regular is absolute, flatpak and snap are relative to the sandbox home.
+	var regular, flatpak, snap string
+	if b.Firefox {
+		regular = filepath.Join(homeDir, ".mozilla", "firefox")
+		flatpak = filepath.Join(".mozilla", "firefox")
+		snap = flatpak
+	} else {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		regular = filepath.Join(configDir, b.ConfigDir)
+		flatpak = filepath.Join("config", b.ConfigDir)
+		snap = b.ConfigDir
+	}
+	locations := []location{{dir: regular}}
+	if b.FlatPak != "" {
+		locations = append(locations, location{
+			dir:    filepath.Join(homeDir, ".var", "app", b.FlatPak, flatpak),
+			launch: []string{"flatpak", "run", b.FlatPak},
+		})
+	}
+	if b.Snap != "" {
+		locations = append(locations, location{
+			dir:    filepath.Join(homeDir, "snap", b.Snap, "common", snap),
+			launch: []string{"snap", "run", b.Snap},
+		})
+	}
+	return locations, nil
+}
+
+// command returns the command line which starts the browser, up to and
+// excluding the --new-window flag.
+func (b *Browser) command() []string {
+	var args []string
+	switch {
+	case len(b.Wrapper) > 0:
+		args = append(args, b.Wrapper...)
+	case len(b.launch) > 0:
+		args = append(args, b.launch...)
+	default:
+		args = append(args, b.Executable)
+	}
+	return append(args, b.Flags...)
+}
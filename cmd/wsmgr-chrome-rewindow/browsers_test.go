@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadBrowsers(t *testing.T) {
+	dir := t.TempDir()
+	old, ok := os.LookupEnv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	defer func() {
+		if ok {
+			os.Setenv("XDG_CONFIG_HOME", old)
+		} else {
+			os.Unsetenv("XDG_CONFIG_HOME")
+		}
+	}()
+	if err := os.MkdirAll(filepath.Join(dir, "wsmgr-for-i3"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	fn := filepath.Join(dir, "wsmgr-for-i3", "browsers.json")
+
+	for _, tt := range []struct {
+		desc    string
+		content string
+		wantErr string
+	}{
+		{
+			desc:    "new browser",
+			content: `{"vivaldi": {"executable": "vivaldi", "class": "Vivaldi-stable", "config_dir": "vivaldi"}}`,
+		},
+
+		{
+			desc:    "user data directory",
+			content: `{"custom": {"executable": "chromium", "user_data_dir": "/tmp/custom"}}`,
+		},
+
+		{
+			desc:    "modified built-in browser",
+			content: `{"chrome": {"flags": ["--force-dark-mode"]}}`,
+		},
+
+		{
+			desc:    "Firefox-based browser",
+			content: `{"librewolf": {"executable": "librewolf", "firefox": true}}`,
+		},
+
+		{
+			desc:    "no executable",
+			content: `{"vivaldi": {"config_dir": "vivaldi"}}`,
+			wantErr: "neither executable nor wrapper",
+		},
+
+		{
+			desc:    "no config directory",
+			content: `{"vivaldi": {"executable": "vivaldi"}}`,
+			wantErr: "neither config_dir nor user_data_dir",
+		},
+
+		{
+			desc:    "built-in config directory removed",
+			content: `{"chrome": {"config_dir": ""}}`,
+			wantErr: "neither config_dir nor user_data_dir",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if err := ioutil.WriteFile(fn, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, _, err := loadBrowsers()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadBrowsers() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/stapelberg/wsmgr-for-i3/internal/sqlite"
+)
+
+// firefoxRoots maps the GUIDs of Firefox’s bookmark roots to the names of the
+// corresponding Chromium bookmark roots.
+var firefoxRoots = map[string]string{
+	"toolbar_____": "bookmark_bar",
+	"unfiled_____": "other",
+	"mobile______": "synced",
+	"menu________": "menu",
+}
+
+// parseINI parses the profiles.ini file format into sections of key/value
+// pairs.
+func parseINI(fn string) (map[string]map[string]string, []string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	sections := make(map[string]map[string]string)
+	var order []string
+	var current map[string]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := line[1 : len(line)-1]
+			current = make(map[string]string)
+			sections[name] = current
+			order = append(order, name)
+			continue
+		}
+		idx := strings.IndexByte(line, '=')
+		if idx == -1 || current == nil {
+			continue
+		}
+		current[line[:idx]] = line[idx+1:]
+	}
+	return sections, order, scanner.Err()
+}
+
+// firefoxProfile returns the directory of the Firefox profile whose name or
+// directory is b.Profile. If b.Profile is empty, the default profile is used:
+// the default profile of the (first) installation, falling back to the profile
+// marked as default, falling back to the first profile.
+func (b *Browser) firefoxProfile() (string, error) {
+	locations, err := b.locations()
+	if err != nil {
+		return "", err
+	}
+	var dirs []string
+	for _, l := range locations {
+		dir := l.dir
+		dirs = append(dirs, dir)
+		sections, order, err := parseINI(filepath.Join(dir, "profiles.ini"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", err
+		}
+		b.launch = l.launch
+		resolve := func(path string, relative bool) string {
+			if relative {
+				return filepath.Join(dir, path)
+			}
+			return path
+		}
+		if b.Profile != "" {
+			var available []string
+			for _, name := range order {
+				if !strings.HasPrefix(name, "Profile") {
+					continue
+				}
+				section := sections[name]
+				if section["Name"] == b.Profile || filepath.Base(section["Path"]) == b.Profile {
+					b.profileName = section["Name"]
+					return resolve(section["Path"], section["IsRelative"] == "1"), nil
+				}
+				available = append(available, fmt.Sprintf("%q (%s)", section["Name"], section["Path"]))
+			}
+			return "", fmt.Errorf("profile %q not found, available profiles: %s", b.Profile, strings.Join(available, ", "))
+		}
+		for _, name := range order {
+			if !strings.HasPrefix(name, "Install") {
+				continue
+			}
+			if path := sections[name]["Default"]; path != "" {
+				return resolve(path, !filepath.IsAbs(path)), nil
+			}
+		}
+		var first string
+		for _, name := range order {
+			if !strings.HasPrefix(name, "Profile") {
+				continue
+			}
+			section := sections[name]
+			path := resolve(section["Path"], section["IsRelative"] == "1")
+			if section["Default"] == "1" {
+				return path, nil
+			}
+			if first == "" {
+				first = path
+			}
+		}
+		if first != "" {
+			return first, nil
+		}
+	}
+	return "", fmt.Errorf("no Firefox profile found (looked for profiles.ini in %s)", strings.Join(dirs, ", "))
+}
+
+// readFirefoxBookmarks reads the bookmarks from the places.sqlite database of
+// the selected Firefox profile. Firefox locks the database while running, so
+// sqlite.Open reads a copy of the database (and its write-ahead log) into
+// memory.
+func (b *Browser) readFirefoxBookmarks() (bookmarkFile, error) {
+	profile, err := b.firefoxProfile()
+	if err != nil {
+		return bookmarkFile{}, err
+	}
+	db, err := sqlite.Open(filepath.Join(profile, "places.sqlite"))
+	if err != nil {
+		return bookmarkFile{}, err
+	}
+	places, err := db.Table("moz_places")
+	if err != nil {
+		return bookmarkFile{}, err
+	}
+	urls := make(map[int64]string, len(places))
+	for _, p := range places {
+		urls[p.Int64("id")] = p.String("url")
+	}
+	rows, err := db.Table("moz_bookmarks")
+	if err != nil {
+		return bookmarkFile{}, err
+	}
+	return firefoxBookmarks(rows, urls), nil
+}
+
+// firefoxBookmarks converts rows of the moz_bookmarks table into the Chromium
+// bookmark structure.
+func firefoxBookmarks(rows []sqlite.Row, urls map[int64]string) bookmarkFile {
+	const (
+		typeBookmark = 1
+		typeFolder   = 2
+	)
+	type node struct {
+		Bookmark
+		position int64
+		children []*node
+	}
+	nodes := make(map[int64]*node, len(rows))
+	for _, row := range rows {
+		n := &node{
+			Bookmark: Bookmark{
+				Guid: row.String("guid"),
+				Name: row.String("title"),
+			},
+			position: row.Int64("position"),
+		}
+		switch row.Int64("type") {
+		case typeBookmark:
+			n.Type = "url"
+			n.URL = urls[row.Int64("fk")]
+			if strings.HasPrefix(n.URL, "place:") {
+				continue // smart bookmark (saved query), cannot be opened
+			}
+		case typeFolder:
+			n.Type = "folder"
+		default:
+			continue // separator
+		}
+		nodes[row.Int64("id")] = n
+	}
+	for _, row := range rows {
+		n, ok := nodes[row.Int64("id")]
+		if !ok {
+			continue
+		}
+		if parent, ok := nodes[row.Int64("parent")]; ok {
+			parent.children = append(parent.children, n)
+		}
+	}
+	var convert func(n *node) Bookmark
+	convert = func(n *node) Bookmark {
+		sort.SliceStable(n.children, func(i, j int) bool {
+			return n.children[i].position < n.children[j].position
+		})
+		bm := n.Bookmark
+		for _, ch := range n.children {
+			bm.Children = append(bm.Children, convert(ch))
+		}
+		return bm
+	}
+	file := bookmarkFile{Roots: make(map[string]bookmarkRoot)}
+	for _, n := range nodes {
+		root, ok := firefoxRoots[n.Guid]
+		if !ok {
+			continue
+		}
+		file.Roots[root] = bookmarkRoot{Children: convert(n).Children}
+	}
+	return file
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+)
+
+// List formats for -list.
+const (
+	formatText = "text" // folder paths, one per line
+	formatJSON = "json" // folder tree, see folderInfo
+)
+
+// folderInfo describes a bookmark folder in -list -format=json output.
+type folderInfo struct {
+	Name          string       `json:"name"`
+	Path          string       `json:"path"` // usable as -name
+	Guid          string       `json:"guid"`
+	Root          string       `json:"root"` // e.g. bookmark_bar, see rootNames
+	URLs          int          `json:"urls"` // bookmarks directly in the folder
+	URLsRecursive int          `json:"urls_recursive"`
+	Folders       []folderInfo `json:"folders"`
+}
+
+// folderTree returns the folder tree of the bookmark folders in children.
+func folderTree(root, dir string, children []Bookmark) []folderInfo {
+	folders := []folderInfo{} // encode as [] instead of null
+	for _, ch := range children {
+		if ch.Type != "folder" {
+			continue
+		}
+		fi := folderInfo{
+			Name:    ch.Name,
+			Path:    path.Join(dir, ch.Name),
+			Guid:    ch.Guid,
+			Root:    root,
+			Folders: folderTree(root, path.Join(dir, ch.Name), ch.Children),
+		}
+		for _, b := range ch.Children {
+			if b.Type == "url" {
+				fi.URLs++
+			}
+		}
+		fi.URLsRecursive = fi.URLs
+		for _, sub := range fi.Folders {
+			fi.URLsRecursive += sub.URLsRecursive
+		}
+		folders = append(folders, fi)
+	}
+	return folders
+}
+
+// listFolders writes the bookmark folders of a to w in the specified format.
+func listFolders(w io.Writer, a bookmarkFile, format string) error {
+	switch format {
+	case formatText:
+		inspect(a, func(dir string, b Bookmark) bool {
+			if b.Type == "folder" {
+				fmt.Fprintln(w, path.Join(dir, b.Name))
+			}
+			return true
+		})
+		return nil
+
+	case formatJSON:
+		tree := []folderInfo{}
+		for _, name := range rootNames {
+			tree = append(tree, folderTree(name, "", a.Roots[name].Children)...)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(tree)
+
+	default:
+		return fmt.Errorf("unknown -format %q (expected %s or %s)", format, formatText, formatJSON)
+	}
+}
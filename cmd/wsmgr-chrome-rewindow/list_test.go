@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestListText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := listFolders(&buf, testBookmarks, formatText); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"work",
+		"work/kint",
+		"kint",
+		"kint/docs",
+		"kint/empty",
+		"reading",
+		"phone",
+		"phone/reading",
+		"menu-only",
+	}, "\n") + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("listFolders(text) = %q, want %q", got, want)
+	}
+}
+
+func TestListJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := listFolders(&buf, testBookmarks, formatJSON); err != nil {
+		t.Fatal(err)
+	}
+	// Folders without subfolders have an empty list, not null.
+	if strings.Contains(buf.String(), "null") {
+		t.Errorf("listFolders(json) contains null: %s", buf.String())
+	}
+	var got []folderInfo
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	none := []folderInfo{}
+	want := []folderInfo{
+		{
+			Name: "work", Path: "work", Guid: "1", Root: "bookmark_bar",
+			URLs: 0, URLsRecursive: 1,
+			Folders: []folderInfo{
+				{Name: "kint", Path: "work/kint", Guid: "2", Root: "bookmark_bar", URLs: 1, URLsRecursive: 1, Folders: none},
+			},
+		},
+		{
+			// Duplicates are counted: the counts are about the bookmarks,
+			// not about the tabs which are opened.
+			Name: "kint", Path: "kint", Guid: "3", Root: "bookmark_bar",
+			URLs: 3, URLsRecursive: 5,
+			Folders: []folderInfo{
+				{Name: "docs", Path: "kint/docs", Guid: "4", Root: "bookmark_bar", URLs: 2, URLsRecursive: 2, Folders: none},
+				{Name: "empty", Path: "kint/empty", Guid: "5", Root: "bookmark_bar", Folders: none},
+			},
+		},
+		{Name: "reading", Path: "reading", Guid: "6", Root: "other", URLs: 1, URLsRecursive: 1, Folders: none},
+		{
+			Name: "phone", Path: "phone", Guid: "7", Root: "synced",
+			URLs: 0, URLsRecursive: 1,
+			Folders: []folderInfo{
+				{Name: "reading", Path: "phone/reading", Guid: "8", Root: "synced", URLs: 1, URLsRecursive: 1, Folders: none},
+			},
+		},
+		{Name: "menu-only", Path: "menu-only", Guid: "9", Root: "menu", Folders: none},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listFolders(json) = %+v, want %+v", got, want)
+	}
+
+	if err := listFolders(&buf, testBookmarks, "yaml"); err == nil {
+		t.Errorf("listFolders(yaml) unexpectedly succeeded")
+	}
+}
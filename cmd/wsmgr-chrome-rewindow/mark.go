@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/stapelberg/wsmgr-for-i3/internal/proc"
+	"github.com/stapelberg/wsmgr-for-i3/internal/wm"
+	"go.i3wm.org/i3/v4"
+)
+
+// markPrefix is the prefix of the i3 marks with which windows opened by
+// wsmgr-chrome-rewindow are marked, followed by the bookmark folder path,
+// e.g. wsmgr-rewindow:work/kint.
+const markPrefix = "wsmgr-rewindow:"
+
+// folderMark returns the mark of the window opened for the bookmark folder
+// with path folderPath (as returned by findFolder), or for its subfolder.
+//
+// The mark is derived from the folder’s path (not from -name), so that e.g.
+// -name=kint and -name=work/kint reuse the same window.
+func folderMark(folderPath, subfolder string) string {
+	mark := markPrefix + folderPath
+	if subfolder != "" {
+		mark += "/" + subfolder
+	}
+	return mark
+}
+
+// Reuse modes specify what to do if a window for the bookmark folder was
+// already opened (and is still open).
+const (
+	reuseMove  = "move"  // move the window to the current workspace
+	reuseFocus = "focus" // focus the window (switching workspaces if needed)
+	reuseNever = "never" // open a new window
+)
+
+// detectTimeout is how long to wait for the browser window to appear.
+const detectTimeout = 10 * time.Second
+
+// i3Quote quotes s for use in a double-quoted i3 command argument.
+func i3Quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+// markCriteria returns i3 command criteria matching the window with mark.
+func markCriteria(mark string) string {
+	return fmt.Sprintf(`[con_mark="%s"]`, i3Quote("^"+regexp.QuoteMeta(mark)+"$"))
+}
+
+// reuseWindow focuses or moves the window with the specified mark, if any, and
+// returns whether such a window exists.
+func reuseWindow(mark, mode string) (bool, error) {
+	marks, err := i3.GetMarks()
+	if err != nil {
+		return false, err
+	}
+	found := false
+	for _, m := range marks {
+		if m == mark {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false, nil
+	}
+	criteria := markCriteria(mark)
+	var cmd string
+	switch mode {
+	case reuseFocus:
+		cmd = criteria + " focus"
+
+	case reuseMove:
+		workspaces, err := i3.GetWorkspaces()
+		if err != nil {
+			return false, err
+		}
+		var current string
+		for _, ws := range workspaces {
+			if ws.Focused {
+				current = ws.Name
+				break
+			}
+		}
+		cmd = fmt.Sprintf(`%s move container to workspace "%s"; %s focus`, criteria, i3Quote(current), criteria)
+
+	default:
+		return false, fmt.Errorf("unknown reuse mode %q (expected %s, %s or %s)", mode, reuseMove, reuseFocus, reuseNever)
+	}
+	log.Printf("reusing window %q: %s", mark, cmd)
+	if _, err := wm.RunCommand(cmd); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// runningPIDs returns the process ids of the open windows with the browser’s
+// window class, i.e. of the already-running browser (if any).
+func (b *Browser) runningPIDs(pids *wm.PIDReader) (map[int]bool, error) {
+	tree, err := i3.GetTree()
+	if err != nil {
+		return nil, err
+	}
+	props, err := wm.Properties()
+	if err != nil {
+		return nil, err
+	}
+	running := make(map[int]bool)
+	tree.Root.FindChild(func(n *i3.Node) bool {
+		if wm.IsWindow(n) && strings.EqualFold(wm.Class(n, props), b.Class) {
+			if pid, err := pids.PID(n); err == nil {
+				running[pid] = true
+			}
+		}
+		return false // visit all nodes
+	})
+	return running, nil
+}
+
+// openAndMark opens the URLs in a new browser window and marks the new window
+// with mark, so that subsequent runs can reuse the window.
+//
+// The new window is recognized as the first new window which was created by
+// the started process (or one of its children, or a process in its session),
+// which is the case when the browser was not yet running, or which has the
+// browser’s window class and was created by the already-running browser (a
+// process which created one of the browser’s windows before), which is the
+// case when the started process handed the URLs to the running browser. New
+// windows of other processes, e.g. of another browser instance, are not marked.
+func (b *Browser) openAndMark(urls []string, mark string) error {
+	recv, err := wm.SubscribeWindowEvents()
+	if err != nil {
+		return err
+	}
+	defer recv.Close()
+	pids, err := wm.NewPIDReader()
+	if err != nil {
+		return err
+	}
+	defer pids.Close()
+	// The running browser’s processes are determined before starting the
+	// browser, as afterwards, windows of the started process would count.
+	running, err := b.runningPIDs(pids)
+	if err != nil {
+		return err
+	}
+
+	// done is closed when openAndMark returns, e.g. because the browser could
+	// not be started, so that the goroutine does not wait for pidc forever.
+	done := make(chan struct{})
+	defer close(done)
+	pidc := make(chan int, 1)
+	windowc := make(chan i3.NodeID, 1)
+	go func() {
+		defer close(windowc)
+		pid := -1
+		for recv.Next() {
+			ev := recv.Event()
+			if ev.Change != "new" {
+				continue
+			}
+			if pid == -1 {
+				select {
+				case pid = <-pidc:
+				case <-done:
+					return
+				}
+			}
+			wpid, err := pids.EventPID(ev)
+			if err != nil {
+				continue // cannot attribute the window
+			}
+			if startedBy(wpid, pid) ||
+				(running[wpid] && strings.EqualFold(ev.Class(), b.Class)) {
+				windowc <- ev.Container.ID
+				return
+			}
+		}
+	}()
+
+	cmd, err := b.openNewWindow(urls)
+	if err != nil {
+		return err
+	}
+	pidc <- cmd.Process.Pid
+	go cmd.Wait() // reap the process once it exits
+
+	timeout := time.AfterFunc(detectTimeout, func() { recv.Close() })
+	defer timeout.Stop()
+	window, ok := <-windowc
+	if !ok {
+		log.Printf("new browser window not found within %v, not marking it", detectTimeout)
+		return nil
+	}
+	// Marks are unique in i3: should another window carry the mark (e.g. when
+	// using -reuse=never), the mark is moved to the new window.
+	markCmd := fmt.Sprintf(`[con_id=%d] mark --add "%s"`, window, i3Quote(mark))
+	log.Printf("marking new window: %s", markCmd)
+	if _, err := wm.RunCommand(markCmd); err != nil {
+		return err
+	}
+	return nil
+}
+
+// startedBy returns whether the process pid is the process started, one of its
+// (transitive) children, or in its session (the started process is a session
+// leader, see openNewWindow).
+func startedBy(pid, started int) bool {
+	for pid > 1 {
+		if pid == started {
+			return true
+		}
+		st, err := proc.ReadStat(pid)
+		if err != nil {
+			return false
+		}
+		if st.Session == started {
+			return true
+		}
+		pid = st.PPID
+	}
+	return false
+}
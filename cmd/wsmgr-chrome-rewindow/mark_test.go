@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stapelberg/wsmgr-for-i3/internal/wm/wmtest"
+)
+
+// fakeBrowser returns a browser whose command records its pid in the returned
+// file and then runs script.
+func fakeBrowser(t *testing.T, script string) (*Browser, string) {
+	t.Helper()
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "pid")
+	fn := filepath.Join(dir, "browser")
+	content := "#!/bin/sh\necho $$ > " + pidFile + ".tmp && mv " + pidFile + ".tmp " + pidFile + "\n" + script
+	if err := ioutil.WriteFile(fn, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &Browser{Wrapper: []string{fn}, Class: "Chromium"}, pidFile
+}
+
+// waitForPID waits for the fake browser to record its pid.
+func waitForPID(t *testing.T, pidFile string) int {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		b, err := ioutil.ReadFile(pidFile)
+		if err != nil {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err == nil {
+			return pid
+		}
+	}
+	t.Fatal("timeout waiting for the browser to start")
+	return 0
+}
+
+// marked returns the ids of the windows with mark.
+func marked(s *wmtest.Server, mark string) []int64 {
+	var ids []int64
+	for _, ws := range s.Workspaces() {
+		for _, w := range ws.Windows {
+			for _, m := range w.Marks {
+				if m == mark {
+					ids = append(ids, w.ID)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+func TestOpenAndMark(t *testing.T) {
+	const mark = markPrefix + "kint"
+	// A process which is neither the started browser nor the running browser,
+	// e.g. another browser instance.
+	other := os.Getpid()
+
+	t.Run("started browser", func(t *testing.T) {
+		s := wmtest.NewServer(t)
+		s.Install()
+		s.AddWorkspace("1: www")
+		b, pidFile := fakeBrowser(t, "exec sleep 10\n")
+
+		errc := make(chan error, 1)
+		go func() { errc <- b.openAndMark([]string{"https://a/"}, mark) }()
+		pid := waitForPID(t, pidFile)
+		defer func() {
+			if p, err := os.FindProcess(pid); err == nil {
+				p.Kill()
+			}
+		}()
+		s.NewWindow(wmtest.Window{AppID: "chromium", PID: other})
+		w := s.NewWindow(wmtest.Window{AppID: "chromium", PID: pid})
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+		if got, want := marked(s, mark), []int64{w.ID}; !reflect.DeepEqual(got, want) {
+			t.Errorf("marked windows = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("running browser", func(t *testing.T) {
+		s := wmtest.NewServer(t)
+		s.Install()
+		// The running browser already has a window open.
+		running := os.Getppid()
+		s.AddWorkspace("1: www", wmtest.Window{AppID: "chromium", PID: running})
+		// Like a browser which hands the URLs to the running browser, the
+		// started process exits right away.
+		b, pidFile := fakeBrowser(t, "")
+
+		errc := make(chan error, 1)
+		go func() { errc <- b.openAndMark([]string{"https://a/"}, mark) }()
+		waitForPID(t, pidFile)
+		s.NewWindow(wmtest.Window{AppID: "chromium", PID: other})
+		s.NewWindow(wmtest.Window{AppID: "foot", PID: running})
+		w := s.NewWindow(wmtest.Window{AppID: "chromium", PID: running})
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+		if got, want := marked(s, mark), []int64{w.ID}; !reflect.DeepEqual(got, want) {
+			t.Errorf("marked windows = %v, want %v", got, want)
+		}
+	})
+}
@@ -8,7 +8,13 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/stapelberg/wsmgr-for-i3/internal/wm"
 )
 
 type Bookmark struct {
@@ -27,27 +33,20 @@ type bookmarkFile struct {
 	Roots map[string]bookmarkRoot `json:"roots"`
 }
 
-type Browser struct {
-	Executable string
-	ConfigDir  string
-	FlatPak    string
-	flat       bool
-}
-
-var browsers = map[string]Browser{
-	"brave":    {Executable: "brave-browser", ConfigDir: "BraveSoftware/Brave-Browser/Default/Bookmarks", FlatPak: "com.brave.Browser"},
-	"chrome":   {Executable: "google-chrome", ConfigDir: "google-chrome/Default/Bookmarks", FlatPak: "com.google.Chrome"},
-	"chromium": {Executable: "chromium-browser", ConfigDir: "chromium/Default/Bookmarks", FlatPak: "org.chromium.Chromium"},
-	"edge":     {Executable: "msedge", ConfigDir: "microsoft-edge/Default/Bookmarks", FlatPak: "com.microsoft.Edge"},
-}
+// rootNames are the bookmark roots which are searched for folders, in order.
+// Chromium uses bookmark_bar, other and synced, Firefox bookmarks are mapped to
+// these (see firefoxRoots), plus the Firefox-only menu.
+var rootNames = []string{"bookmark_bar", "other", "synced", "menu"}
 
-func inspectChildren(children []Bookmark, f func(Bookmark) bool) bool {
+// inspectChildren calls f for each bookmark, with the path of the folder
+// containing the bookmark (folder names, separated by slashes).
+func inspectChildren(dir string, children []Bookmark, f func(string, Bookmark) bool) bool {
 	for _, ch := range children {
-		if !f(ch) {
+		if !f(dir, ch) {
 			return false
 		}
 		if ch.Type == "folder" {
-			if !inspectChildren(ch.Children, f) {
+			if !inspectChildren(path.Join(dir, ch.Name), ch.Children, f) {
 				return false
 			}
 		}
@@ -55,107 +54,412 @@ func inspectChildren(children []Bookmark, f func(Bookmark) bool) bool {
 	return true
 }
 
-func inspect(root bookmarkRoot, f func(Bookmark) bool) {
-	inspectChildren(root.Children, f)
+// inspect calls f for each bookmark in all roots.
+func inspect(a bookmarkFile, f func(string, Bookmark) bool) {
+	for _, name := range rootNames {
+		if !inspectChildren("", a.Roots[name].Children, f) {
+			return
+		}
+	}
 }
 
-func (b *Browser) openNewWindow(children []Bookmark) {
-	var cmd *exec.Cmd
-	if b.flat {
-		cmd = exec.Command("flatpak", "run", b.FlatPak, "--new-window")
-	} else {
-		cmd = exec.Command(b.Executable, "--new-window")
-	}
+// findFolder returns the folder whose path is name, or, if there is no such
+// folder, the first folder whose path ends in name, e.g. kint matches the
+// folders kint and work/kint, whereas work/kint only matches work/kint. The
+// path of the folder is returned, too.
+func findFolder(a bookmarkFile, name string) (Bookmark, string, bool) {
+	name = strings.Trim(name, "/")
+	var (
+		folder     Bookmark
+		folderPath string
+		found      bool
+	)
+	inspect(a, func(dir string, b Bookmark) bool {
+		if b.Type != "folder" {
+			return true
+		}
+		p := path.Join(dir, b.Name)
+		if p == name {
+			folder, folderPath = b, p
+			found = true
+			return false // exact match
+		}
+		if !found && strings.HasSuffix(p, "/"+name) {
+			folder, folderPath = b, p
+			found = true
+		}
+		return true
+	})
+	return folder, folderPath, found
+}
+
+// Subfolder modes specify how subfolders of the opened bookmark folder are
+// handled.
+const (
+	subfoldersFlatten = "flatten" // open all bookmarks in one window
+	subfoldersWindows = "windows" // open one window per subfolder
+	subfoldersIgnore  = "ignore"  // only open the folder’s direct bookmarks
+)
+
+// urlCollector collects URLs to open, skipping duplicates.
+type urlCollector struct {
+	seen map[string]bool
+}
+
+// collect returns the URLs of the bookmarks in children, including those in
+// subfolders if recursive is true. Non-URL entries and URLs which were already
+// collected are skipped.
+func (c *urlCollector) collect(children []Bookmark, recursive bool) []string {
+	var urls []string
 	for _, ch := range children {
-		cmd.Args = append(cmd.Args, ch.URL)
+		if ch.Type == "folder" {
+			if recursive {
+				urls = append(urls, c.collect(ch.Children, recursive)...)
+			}
+			continue
+		}
+		if ch.Type != "url" || ch.URL == "" {
+			continue
+		}
+		if c.seen[ch.URL] {
+			continue
+		}
+		c.seen[ch.URL] = true
+		urls = append(urls, ch.URL)
 	}
+	return urls
+}
+
+// browserWindow is a browser window to open.
+type browserWindow struct {
+	subfolder string // empty for the window of the folder itself
+	urls      []string
+}
+
+// windows returns the windows to open for the bookmark folder.
+func windows(folder Bookmark, subfolders string) ([]browserWindow, error) {
+	c := &urlCollector{seen: make(map[string]bool)}
+	switch subfolders {
+	case subfoldersFlatten:
+		return []browserWindow{{urls: c.collect(folder.Children, true)}}, nil
+
+	case subfoldersWindows:
+		result := []browserWindow{{urls: c.collect(folder.Children, false)}}
+		for _, ch := range folder.Children {
+			if ch.Type == "folder" {
+				result = append(result, browserWindow{
+					subfolder: ch.Name,
+					urls:      c.collect(ch.Children, true),
+				})
+			}
+		}
+		return result, nil
+
+	case subfoldersIgnore:
+		for _, ch := range folder.Children {
+			if ch.Type == "folder" {
+				log.Printf("warning: ignoring subfolder %q of %q", ch.Name, folder.Name)
+			}
+		}
+		return []browserWindow{{urls: c.collect(folder.Children, false)}}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown subfolder mode %q (expected %s, %s or %s)", subfolders, subfoldersFlatten, subfoldersWindows, subfoldersIgnore)
+	}
+}
+
+// limitTabs returns the first max URLs (all URLs if max is 0), logging a
+// warning if URLs of what are not opened.
+func limitTabs(urls []string, max int, what string) []string {
+	if max > 0 && len(urls) > max {
+		log.Printf("warning: %s contains %d URLs, only opening the first %d (see -max-tabs)", what, len(urls), max)
+		return urls[:max]
+	}
+	return urls
+}
+
+// openNewWindow starts the browser to open the URLs in a new window (or the
+// URL in a new app window, see Browser.App). If the browser is already running,
+// the started process exits after handing the URLs to the running browser.
+// Otherwise, the started process is the browser itself, so openNewWindow does
+// not wait for it to exit.
+func (b *Browser) openNewWindow(urls []string) (*exec.Cmd, error) {
+	args := b.command()
+	if !b.Firefox && b.profileDir != "" && b.profileDir != "Default" {
+		args = append(args, "--profile-directory="+b.profileDir)
+	}
+	if b.Firefox && b.profileName != "" {
+		args = append(args, "-P", b.profileName)
+	}
+	if b.Incognito {
+		args = append(args, "--incognito")
+	}
+	if b.App {
+		args = append(args, "--app="+urls[0])
+	} else {
+		args = append(args, "--new-window")
+		args = append(args, urls...)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
 	log.Printf("%v", cmd)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
-	if err := cmd.Run(); err != nil {
-		log.Fatal(err)
+	// Start the browser in a new session, so that its windows are recognized
+	// even if they are created by forked-off children (see openAndMark).
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return nil, err
 	}
+	return cmd, nil
 }
 
-func (b *Browser) readFile() ([]byte, error) {
-	configDir, err := os.UserConfigDir()
+// userDataDir returns the Chromium user data directory, which contains the
+// Local State file and one directory per profile: the first of the browser’s
+// locations which exists.
+func (b *Browser) userDataDir() (string, error) {
+	locations, err := b.locations()
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	for _, l := range locations {
+		if _, err := os.Stat(l.dir); err == nil {
+			b.launch = l.launch
+			return l.dir, nil
+		}
 	}
+	return locations[0].dir, nil
+}
 
-	bytes, err := ioutil.ReadFile(filepath.Join(configDir, b.ConfigDir))
-	if err == nil {
-		return bytes, nil
+// localState is the part of Chromium’s Local State file which lists profiles.
+type localState struct {
+	Profile struct {
+		InfoCache map[string]struct {
+			Name string `json:"name"`
+		} `json:"info_cache"`
+	} `json:"profile"`
+}
+
+// chromiumProfile returns the profile directory (e.g. “Profile 1”) for
+// b.Profile, which is either a profile directory or the name of a profile as
+// displayed by the browser (e.g. “Work”).
+func (b *Browser) chromiumProfile(userDataDir string) (string, error) {
+	if b.Profile == "" {
+		return "Default", nil
+	}
+	if st, err := os.Stat(filepath.Join(userDataDir, b.Profile)); err == nil && st.IsDir() {
+		return b.Profile, nil
+	}
+	bytes, err := ioutil.ReadFile(filepath.Join(userDataDir, "Local State"))
+	if err != nil {
+		return "", err
+	}
+	var ls localState
+	if err := json.Unmarshal(bytes, &ls); err != nil {
+		return "", err
+	}
+	var available []string
+	for dir, info := range ls.Profile.InfoCache {
+		if info.Name == b.Profile {
+			return dir, nil
+		}
+		available = append(available, fmt.Sprintf("%q (%s)", info.Name, dir))
 	}
+	sort.Strings(available)
+	return "", fmt.Errorf("profile %q not found, available profiles: %s", b.Profile, strings.Join(available, ", "))
+}
 
-	homeDir, err := os.UserHomeDir()
+// resolveProfile resolves the user data directory and the profile directory
+// of a Chromium-based browser.
+func (b *Browser) resolveProfile() error {
+	userDataDir, err := b.userDataDir()
 	if err != nil {
-		return nil, err
+		return err
 	}
+	b.profileDir, err = b.chromiumProfile(userDataDir)
+	if err != nil {
+		return err
+	}
+	b.dataDir = userDataDir
+	return nil
+}
 
-	b.flat = true
+func (b *Browser) readFile() ([]byte, error) {
+	if err := b.resolveProfile(); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(filepath.Join(b.dataDir, b.profileDir, "Bookmarks"))
+}
 
-	return ioutil.ReadFile(filepath.Join(homeDir, ".var/app", b.FlatPak, "config", b.ConfigDir))
+func (b *Browser) readBookmarks() (bookmarkFile, error) {
+	if b.Firefox {
+		return b.readFirefoxBookmarks()
+	}
+	bytes, err := b.readFile()
+	if err != nil {
+		return bookmarkFile{}, err
+	}
+	var a bookmarkFile
+	if err := json.Unmarshal(bytes, &a); err != nil {
+		return bookmarkFile{}, err
+	}
+	return a, nil
 }
 
 func rewindow() error {
 	var (
-		list    = flag.Bool("list", false, "list bookmark folder names")
-		browser = flag.String("browser", "google-chrome", "your preferred chromium flavour (default google-chrome)")
-		name    = flag.String("name", "", "name of the bookmark folder to open in a new window")
+		list       = flag.Bool("list", false, "list bookmark folder paths")
+		format     = flag.String("format", formatText, "-list output format: "+formatText+" (one folder path per line) or "+formatJSON+" (folder tree with GUIDs and bookmark counts)")
+		browser    = flag.String("browser", "", "your preferred chromium flavour (e.g. chrome), or firefox, or a browser from browsers.json (default: the first installed browser)")
+		profile    = flag.String("profile", "", "browser profile (directory or name) to read bookmarks from (default: the default profile)")
+		name       = flag.String("name", "", "name or path (e.g. work/kint) of the bookmark folder to open in a new window")
+		subfolders = flag.String("subfolders", subfoldersFlatten, "how to open subfolders: "+subfoldersFlatten+" (all bookmarks in one window), "+subfoldersWindows+" (one window per subfolder) or "+subfoldersIgnore)
+		maxTabs    = flag.Int("max-tabs", 50, "maximum number of tabs to open per window (0 for no limit)")
+		reuse      = flag.String("reuse", reuseMove, "what to do if a window for the folder was already opened: "+reuseMove+" it to the current workspace, "+reuseFocus+" it, or "+reuseNever+" reuse it (open a new window)")
+		save       = flag.Bool("save", false, "save the tabs of the browser window on the current workspace to the -name bookmark folder (requires -devtools-port, updates the bookmarks once the browser exits)")
+		devtools   = flag.Int("devtools-port", 9222, "local remote debugging port of the browser (see --remote-debugging-port), for -save")
+		app        = flag.Bool("app", false, "open each bookmark in its own app window (--app), without tabs and address bar")
+		incognito  = flag.Bool("incognito", false, "open windows in incognito mode")
+		urlsFile   = flag.String("urls", "", "open the URLs listed in this file (one per line) instead of a bookmark folder")
+		workspace  = flag.String("workspace", "", "workspace name, for the {name} placeholder in -urls")
+		cwd        = flag.String("cwd", "", "working directory of the workspace, for the {cwd} and git placeholders in -urls")
+		flags      stringList
 	)
+	flag.Var(&flags, "flag", "extra browser command line flag, e.g. -flag=--force-dark-mode (can be repeated)")
 	flag.Parse()
 
+	browsers, order, err := loadBrowsers()
+	if err != nil {
+		return err
+	}
+	if *browser == "" {
+		*browser, err = detectBrowser(browsers, order)
+		if err != nil {
+			return err
+		}
+	}
 	config, ok := browsers[*browser]
 	if !ok {
 		log.Printf("Supported browsers:")
-		for browser := range browsers {
+		for _, browser := range order {
 			log.Printf("  - %s", browser)
 		}
 		log.Println()
-		log.Fatalf("Browser '%s' not supported (yet, feel free to create a PR)", *browser)
+		log.Fatalf("Browser '%s' not supported (add it to ~/.config/wsmgr-for-i3/browsers.json)", *browser)
+	}
+	config.Profile = *profile
+	config.App = *app
+	config.Incognito = *incognito
+	config.Flags = append(config.Flags, flags...)
+	if config.Firefox && (config.App || config.Incognito) {
+		return fmt.Errorf("-app and -incognito are not supported for Firefox")
 	}
 
-	b, err := config.readFile()
-	if err != nil {
-		return err
+	// open opens the URLs in a new window (or app windows) marked with mark,
+	// unless a window with mark can be reused.
+	open := func(urls []string, mark, what string) error {
+		urls = limitTabs(urls, *maxTabs, what)
+		// App windows are marked individually, by URL.
+		groups := [][]string{urls}
+		marks := []string{mark}
+		if config.App {
+			groups, marks = nil, nil
+			for _, u := range urls {
+				groups = append(groups, []string{u})
+				marks = append(marks, mark+"@"+u)
+			}
+		}
+		for idx, urls := range groups {
+			if *reuse != reuseNever {
+				found, err := reuseWindow(marks[idx], *reuse)
+				if err != nil {
+					return err
+				}
+				if found {
+					continue
+				}
+			}
+			log.Printf("opening %s in new %s window", what, *browser)
+			if err := config.openAndMark(urls, marks[idx]); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
-	var a bookmarkFile
-	if err := json.Unmarshal(b, &a); err != nil {
+
+	if *urlsFile != "" {
+		urls, err := readURLs(*urlsFile, &placeholders{workspace: *workspace, cwd: *cwd})
+		if err != nil {
+			return err
+		}
+		if len(urls) == 0 {
+			return fmt.Errorf("%s: no URLs", *urlsFile)
+		}
+		if config.Firefox && config.Profile != "" {
+			if _, err := config.firefoxProfile(); err != nil {
+				return err
+			}
+		} else if !config.Firefox {
+			if err := config.resolveProfile(); err != nil {
+				return err
+			}
+		}
+		mark := urlsMarkPrefix + *workspace
+		if *workspace == "" {
+			mark = urlsMarkPrefix + *urlsFile
+		}
+		return open(urls, mark, *urlsFile)
+	}
+
+	a, err := config.readBookmarks()
+	if err != nil {
 		return err
 	}
 	if *list {
-		var folderNames []string
-		inspect(a.Roots["bookmark_bar"], func(b Bookmark) bool {
-			if b.Type == "folder" {
-				folderNames = append(folderNames, b.Name)
-			}
-			return true
-		})
-		for _, name := range folderNames {
-			fmt.Println(name)
-		}
-		return nil
+		return listFolders(os.Stdout, a, *format)
 	}
 
 	if *name == "" {
-		return fmt.Errorf("neither -list nor -name specified")
+		return fmt.Errorf("neither -list, -name nor -urls specified")
 	}
 
-	inspect(a.Roots["bookmark_bar"], func(b Bookmark) bool {
-		if b.Type == "folder" && b.Name == *name {
-			log.Printf("opening folder %q in new %s window", b.Name, *browser)
-			config.openNewWindow(b.Children)
-			return false
+	folder, folderPath, ok := findFolder(a, *name)
+	if !ok {
+		return fmt.Errorf("bookmark folder %q not found", *name)
+	}
+	if *save {
+		return config.save(folderPath, *devtools)
+	}
+	wins, err := windows(folder, *subfolders)
+	if err != nil {
+		return err
+	}
+	for _, win := range wins {
+		urls := win.urls
+		if len(urls) == 0 {
+			continue
 		}
-		return true
-	})
+		mark := folderMark(folderPath, win.subfolder)
+		if err := open(urls, mark, fmt.Sprintf("folder %q", folder.Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stringList is a flag.Value which collects the values of a repeated flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, " ") }
 
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
 	return nil
 }
 
 func main() {
+	wm.Init()
 	if err := rewindow(); err != nil {
 		log.Fatal(err)
 	}
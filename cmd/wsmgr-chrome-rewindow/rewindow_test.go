@@ -0,0 +1,143 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func folder(guid, name string, children ...Bookmark) Bookmark {
+	return Bookmark{Guid: guid, Name: name, Type: "folder", Children: children}
+}
+
+func link(u string) Bookmark {
+	return Bookmark{Name: u, URL: u, Type: "url"}
+}
+
+// testBookmarks is a bookmark file with folders in all roots.
+var testBookmarks = bookmarkFile{
+	Roots: map[string]bookmarkRoot{
+		"bookmark_bar": {Children: []Bookmark{
+			folder("1", "work",
+				folder("2", "kint", link("https://w1/"))),
+			folder("3", "kint",
+				link("https://a/"),
+				link("https://b/"),
+				folder("4", "docs",
+					link("https://c/"),
+					link("https://a/")),
+				folder("5", "empty"),
+				link("https://b/")),
+			link("https://top/"),
+		}},
+		"other": {Children: []Bookmark{
+			folder("6", "reading", link("https://r/")),
+		}},
+		"synced": {Children: []Bookmark{
+			folder("7", "phone",
+				folder("8", "reading", link("https://p/"))),
+		}},
+		"menu": {Children: []Bookmark{
+			folder("9", "menu-only"),
+		}},
+	},
+}
+
+func TestFindFolder(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		wantGuid string
+		wantPath string // empty if not found
+	}{
+		// An exact match is preferred over an earlier suffix match.
+		{name: "kint", wantGuid: "3", wantPath: "kint"},
+		{name: "work/kint", wantGuid: "2", wantPath: "work/kint"},
+		{name: "/kint/", wantGuid: "3", wantPath: "kint"},
+		{name: "docs", wantGuid: "4", wantPath: "kint/docs"},
+		{name: "kint/docs", wantGuid: "4", wantPath: "kint/docs"},
+		// All roots are searched, exact matches first.
+		{name: "reading", wantGuid: "6", wantPath: "reading"},
+		{name: "phone/reading", wantGuid: "8", wantPath: "phone/reading"},
+		{name: "menu-only", wantGuid: "9", wantPath: "menu-only"},
+		// Suffixes only match whole folder names.
+		{name: "int"},
+		{name: "missing"},
+	} {
+		f, p, ok := findFolder(testBookmarks, tt.name)
+		if ok != (tt.wantPath != "") {
+			t.Errorf("findFolder(%q) found = %v, want %v", tt.name, ok, !ok)
+			continue
+		}
+		if p != tt.wantPath {
+			t.Errorf("findFolder(%q) path = %q, want %q", tt.name, p, tt.wantPath)
+		}
+		if f.Guid != tt.wantGuid {
+			t.Errorf("findFolder(%q) = folder %q, want %q", tt.name, f.Guid, tt.wantGuid)
+		}
+	}
+}
+
+func TestWindows(t *testing.T) {
+	kint, _, ok := findFolder(testBookmarks, "kint")
+	if !ok {
+		t.Fatal("folder kint not found")
+	}
+	for _, tt := range []struct {
+		subfolders string
+		want       []browserWindow
+	}{
+		{
+			// Duplicates (also in subfolders) are only opened once.
+			subfolders: subfoldersFlatten,
+			want: []browserWindow{
+				{urls: []string{"https://a/", "https://b/", "https://c/"}},
+			},
+		},
+
+		{
+			// URLs of the folder itself are opened first, so subfolders skip
+			// them; empty subfolders yield an empty window (not opened).
+			subfolders: subfoldersWindows,
+			want: []browserWindow{
+				{urls: []string{"https://a/", "https://b/"}},
+				{subfolder: "docs", urls: []string{"https://c/"}},
+				{subfolder: "empty"},
+			},
+		},
+
+		{
+			subfolders: subfoldersIgnore,
+			want: []browserWindow{
+				{urls: []string{"https://a/", "https://b/"}},
+			},
+		},
+	} {
+		got, err := windows(kint, tt.subfolders)
+		if err != nil {
+			t.Fatalf("windows(%s): %v", tt.subfolders, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("windows(%s) = %+v, want %+v", tt.subfolders, got, tt.want)
+		}
+	}
+
+	if _, err := windows(kint, "nope"); err == nil {
+		t.Errorf("windows(nope) unexpectedly succeeded")
+	}
+}
+
+func TestLimitTabs(t *testing.T) {
+	urls := []string{"https://a/", "https://b/", "https://c/"}
+	for _, tt := range []struct {
+		max  int
+		want []string
+	}{
+		{max: 0, want: urls},
+		{max: 2, want: urls[:2]},
+		{max: 3, want: urls},
+		{max: 10, want: urls},
+	} {
+		if got := limitTabs(urls, tt.max, "folder"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("limitTabs(max=%d) = %q, want %q", tt.max, got, tt.want)
+		}
+	}
+}
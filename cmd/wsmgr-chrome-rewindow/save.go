@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/renameio/v2"
+	"github.com/stapelberg/wsmgr-for-i3/internal/cdp"
+	"github.com/stapelberg/wsmgr-for-i3/internal/wm"
+	"go.i3wm.org/i3/v4"
+)
+
+// save reads the tabs of the browser window on the current workspace via the
+// DevTools protocol and replaces the bookmarks in the bookmark folder with
+// them. Subfolders of the bookmark folder are kept. folderPath is the path of
+// the folder as returned by findFolder.
+//
+// Chromium keeps the bookmarks in memory and overwrites the Bookmarks file
+// whenever they change, and there is no bookmarks API in the DevTools protocol,
+// so save waits until the browser has exited before updating the file.
+func (b *Browser) save(folderPath string, port int) error {
+	if b.Firefox {
+		return fmt.Errorf("-save is not supported for Firefox")
+	}
+	tabs, err := b.workspaceTabs(folderMark(folderPath, ""), port)
+	if err != nil {
+		return err
+	}
+	if len(tabs) == 0 {
+		return fmt.Errorf("browser window has no tabs")
+	}
+	for _, tab := range tabs {
+		log.Printf("  tab %q (%s)", tab.Title, tab.URL)
+	}
+	if err := b.waitForExit(); err != nil {
+		return err
+	}
+	fn := filepath.Join(b.dataDir, b.profileDir, "Bookmarks")
+	if err := updateFolder(fn, folderPath, tabs); err != nil {
+		return err
+	}
+	log.Printf("saved %d tabs to bookmark folder %q", len(tabs), folderPath)
+	return nil
+}
+
+// workspaceTabs returns the tabs of the browser window on the current
+// workspace: the window which carries mark, or the focused browser window, or
+// any browser window.
+func (b *Browser) workspaceTabs(mark string, port int) ([]cdp.Target, error) {
+	window, err := b.workspaceWindow(mark)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("reading tabs of window %q", window.Name)
+
+	version, err := cdp.GetVersion(port)
+	if err != nil {
+		return nil, fmt.Errorf("%v (start the browser with --remote-debugging-port=%d)", err, port)
+	}
+	conn, err := cdp.Dial(version.WebSocketDebuggerURL)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	targets, err := conn.Targets()
+	if err != nil {
+		return nil, err
+	}
+	type browserWindow struct {
+		bounds cdp.Bounds
+		tabs   []cdp.Target
+	}
+	windows := make(map[int64]*browserWindow)
+	var order []int64
+	for _, t := range targets {
+		if t.Type != "page" || strings.HasPrefix(t.URL, "devtools://") {
+			continue
+		}
+		id, bounds, err := conn.WindowForTarget(t.TargetID)
+		if err != nil {
+			return nil, err
+		}
+		w, ok := windows[id]
+		if !ok {
+			w = &browserWindow{bounds: bounds}
+			windows[id] = w
+			order = append(order, id)
+		}
+		w.tabs = append(w.tabs, t)
+	}
+
+	// X11 window titles are “<title of the active tab> - <browser name>”.
+	// Prefer browser windows which contain a tab with a matching title, then
+	// select the browser window whose bounds are closest to the X11 window.
+	titleMatches := func(w *browserWindow) bool {
+		for _, t := range w.tabs {
+			if t.Title != "" && (window.Name == t.Title || strings.HasPrefix(window.Name, t.Title+" - ")) {
+				return true
+			}
+		}
+		return false
+	}
+	abs := func(i int64) int64 {
+		if i < 0 {
+			return -i
+		}
+		return i
+	}
+	distance := func(w *browserWindow) int64 {
+		r := window.Rect
+		return abs(w.bounds.Left-r.X) + abs(w.bounds.Top-r.Y) + abs(w.bounds.Width-r.Width) + abs(w.bounds.Height-r.Height)
+	}
+	var best *browserWindow
+	for _, id := range order {
+		w := windows[id]
+		switch {
+		case best == nil:
+			best = w
+		case titleMatches(w) != titleMatches(best):
+			if titleMatches(w) {
+				best = w
+			}
+		case distance(w) < distance(best):
+			best = w
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("browser has no open windows")
+	}
+	return best.tabs, nil
+}
+
+// workspaceWindow returns the browser window on the current workspace.
+func (b *Browser) workspaceWindow(mark string) (*i3.Node, error) {
+	tree, err := i3.GetTree()
+	if err != nil {
+		return nil, err
+	}
+	ws := tree.Root.FindFocused(func(n *i3.Node) bool { return n.Type == i3.WorkspaceNode })
+	if ws == nil {
+		return nil, fmt.Errorf("could not locate workspace")
+	}
+	props, err := wm.Properties() // app_id of Wayland windows
+	if err != nil {
+		return nil, err
+	}
+	var candidates []*i3.Node
+	ws.FindChild(func(n *i3.Node) bool {
+		if wm.IsWindow(n) && strings.EqualFold(wm.Class(n, props), b.Class) {
+			candidates = append(candidates, n)
+		}
+		return false // visit all nodes
+	})
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no %s window found on workspace %q", b.Executable, ws.Name)
+	}
+	for _, n := range candidates {
+		for _, m := range n.Marks {
+			if m == mark {
+				return n, nil
+			}
+		}
+	}
+	focused := ws.FindFocused(wm.IsWindow)
+	for _, n := range candidates {
+		if focused != nil && n.ID == focused.ID {
+			return n, nil
+		}
+	}
+	return candidates[0], nil
+}
+
+// waitForExit waits until the browser is no longer running, i.e. until the
+// SingletonLock symlink in the user data directory is gone.
+func (b *Browser) waitForExit() error {
+	lock := filepath.Join(b.dataDir, "SingletonLock")
+	logged := false
+	for {
+		_, err := os.Lstat(lock)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !logged {
+			log.Printf("waiting for %s to exit before updating its bookmarks (it would overwrite them otherwise)", b.Executable)
+			logged = true
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// updateFolder replaces the bookmarks in the folder name of the Bookmarks file
+// fn with tabs. All other contents of the file are preserved. Existing
+// bookmarks for the same URLs are kept (including their ids and creation
+// dates), subfolders are kept after the bookmarks.
+func updateFolder(fn, name string, tabs []cdp.Target) error {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	var file map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&file); err != nil {
+		return err
+	}
+	roots, _ := file["roots"].(map[string]interface{})
+	folder := findRawFolder(roots, name)
+	if folder == nil {
+		return fmt.Errorf("bookmark folder %q not found", name)
+	}
+
+	var maxID int64
+	var walk func(node map[string]interface{})
+	walk = func(node map[string]interface{}) {
+		if id, err := strconv.ParseInt(fmt.Sprint(node["id"]), 10, 64); err == nil && id > maxID {
+			maxID = id
+		}
+		children, _ := node["children"].([]interface{})
+		for _, ch := range children {
+			if ch, ok := ch.(map[string]interface{}); ok {
+				walk(ch)
+			}
+		}
+	}
+	for _, root := range roots {
+		if root, ok := root.(map[string]interface{}); ok {
+			walk(root)
+		}
+	}
+
+	now := chromeTime(time.Now())
+	existing := make(map[string]map[string]interface{})
+	var subfolders []interface{}
+	children, _ := folder["children"].([]interface{})
+	for _, ch := range children {
+		node, ok := ch.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if node["type"] == "folder" {
+			subfolders = append(subfolders, node)
+			continue
+		}
+		if url, ok := node["url"].(string); ok {
+			existing[url] = node
+		}
+	}
+	var updated []interface{}
+	for _, tab := range tabs {
+		if node, ok := existing[tab.URL]; ok {
+			delete(existing, tab.URL) // only reuse once
+			node["name"] = tab.Title
+			updated = append(updated, node)
+			continue
+		}
+		guid, err := newGUID()
+		if err != nil {
+			return err
+		}
+		maxID++
+		updated = append(updated, map[string]interface{}{
+			"date_added":     now,
+			"date_last_used": "0",
+			"guid":           guid,
+			"id":             strconv.FormatInt(maxID, 10),
+			"name":           tab.Title,
+			"type":           "url",
+			"url":            tab.URL,
+		})
+	}
+	folder["children"] = append(updated, subfolders...)
+	folder["date_modified"] = now
+	// The checksum covers all bookmarks and would no longer match. Chromium
+	// only verifies the checksum if it is present.
+	delete(file, "checksum")
+
+	out, err := json.MarshalIndent(file, "", "   ")
+	if err != nil {
+		return err
+	}
+	return renameio.WriteFile(fn, out, 0600)
+}
+
+// findRawFolder is like findFolder, but operates on the decoded JSON so that
+// the folder can be modified without losing any fields.
+func findRawFolder(roots map[string]interface{}, name string) map[string]interface{} {
+	name = strings.Trim(name, "/")
+	var suffixMatch map[string]interface{}
+	var walk func(dir string, node map[string]interface{}) map[string]interface{}
+	walk = func(dir string, node map[string]interface{}) map[string]interface{} {
+		children, _ := node["children"].([]interface{})
+		for _, ch := range children {
+			ch, ok := ch.(map[string]interface{})
+			if !ok || ch["type"] != "folder" {
+				continue
+			}
+			chName, _ := ch["name"].(string)
+			p := path.Join(dir, chName)
+			if p == name {
+				return ch
+			}
+			if suffixMatch == nil && strings.HasSuffix(p, "/"+name) {
+				suffixMatch = ch
+			}
+			if found := walk(p, ch); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	for _, rootName := range rootNames {
+		root, ok := roots[rootName].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if found := walk("", root); found != nil {
+			return found
+		}
+	}
+	return suffixMatch
+}
+
+// chromeTime returns t in Chromium’s bookmark time format: microseconds since
+// 1601-01-01 (the Windows epoch), as a decimal string.
+func chromeTime(t time.Time) string {
+	const windowsToUnixEpochMicros = 11644473600 * 1000 * 1000
+	return strconv.FormatInt(t.UnixNano()/1000+windowsToUnixEpochMicros, 10)
+}
+
+// newGUID returns a random (version 4) UUID.
+func newGUID() (string, error) {
+	var u [16]byte
+	if _, err := io.ReadFull(rand.Reader, u[:]); err != nil {
+		return "", err
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16]), nil
+}
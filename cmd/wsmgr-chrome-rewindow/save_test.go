@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stapelberg/wsmgr-for-i3/internal/cdp"
+	"github.com/stapelberg/wsmgr-for-i3/internal/wm/wmtest"
+)
+
+func TestSaveMark(t *testing.T) {
+	for _, tt := range []struct {
+		name string // -name flag
+		want string // mark of the window opened for the folder
+	}{
+		{name: "kint", want: "wsmgr-rewindow:kint"},
+		{name: "/kint/", want: "wsmgr-rewindow:kint"},
+		{name: "docs", want: "wsmgr-rewindow:kint/docs"},
+		{name: "phone/reading", want: "wsmgr-rewindow:phone/reading"},
+	} {
+		_, folderPath, ok := findFolder(testBookmarks, tt.name)
+		if !ok {
+			t.Fatalf("folder %q not found", tt.name)
+		}
+		if got := folderMark(folderPath, ""); got != tt.want {
+			t.Errorf("-name=%s: mark = %q, want %q", tt.name, got, tt.want)
+		}
+
+		// -save reads the tabs of the window which was opened (and marked)
+		// for the folder, even if another browser window is focused.
+		s := wmtest.NewServer(t)
+		s.Install()
+		s.AddWorkspace("1: www",
+			wmtest.Window{AppID: "chromium", PID: 1, Marks: []string{tt.want}},
+			wmtest.Window{AppID: "chromium", PID: 1})
+		b := &Browser{Class: "Chromium"}
+		window, err := b.workspaceWindow(folderMark(folderPath, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := int64(window.ID), s.Workspaces()[0].Windows[0].ID; got != want {
+			t.Errorf("-name=%s: -save reads window %d, want %d", tt.name, got, want)
+		}
+	}
+}
+
+func TestUpdateFolder(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "Bookmarks")
+	const bookmarks = `{
+  "roots": {
+    "bookmark_bar": {"children": [
+      {"id": "1", "name": "work", "type": "folder", "children": [
+        {"id": "2", "name": "kint", "type": "folder", "children": [
+          {"id": "3", "name": "A", "type": "url", "url": "https://a/"}
+        ]}
+      ]},
+      {"id": "4", "name": "kint", "type": "folder", "children": [
+        {"id": "5", "name": "B", "type": "url", "url": "https://b/"},
+        {"id": "6", "name": "docs", "type": "folder", "children": []}
+      ]}
+    ]}
+  },
+  "checksum": "0123"
+}`
+	if err := ioutil.WriteFile(fn, []byte(bookmarks), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tabs := []cdp.Target{
+		{Title: "B (renamed)", URL: "https://b/"},
+		{Title: "C", URL: "https://c/"},
+	}
+	if err := updateFolder(fn, "kint", tabs); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file map[string]interface{}
+	if err := json.Unmarshal(b, &file); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := file["checksum"]; ok {
+		t.Errorf("checksum not removed")
+	}
+	var a bookmarkFile
+	if err := json.Unmarshal(b, &a); err != nil {
+		t.Fatal(err)
+	}
+	names := func(path string) []string {
+		folder, _, ok := findFolder(a, path)
+		if !ok {
+			t.Fatalf("folder %q not found", path)
+		}
+		var names []string
+		for _, ch := range folder.Children {
+			names = append(names, ch.Name)
+		}
+		return names
+	}
+	// The bookmarks are replaced, subfolders are kept after them.
+	if got, want := names("kint"), []string{"B (renamed)", "C", "docs"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kint = %q, want %q", got, want)
+	}
+	// Other folders with the same name are not modified.
+	if got, want := names("work/kint"), []string{"A"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("work/kint = %q, want %q", got, want)
+	}
+}
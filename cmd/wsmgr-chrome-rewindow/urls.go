@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// urlsMarkPrefix is the prefix of the i3 marks with which windows opened from
+// a urls file are marked, followed by the workspace name.
+const urlsMarkPrefix = "wsmgr-rewindow-urls:"
+
+// placeholders expands {placeholder}s in URL templates. The git placeholders
+// are only determined when used.
+type placeholders struct {
+	workspace string // workspace name
+	cwd       string // working directory of the workspace
+
+	git map[string]string
+}
+
+var placeholderRe = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// expand replaces the placeholders in tmpl:
+//
+//	{name}    workspace name
+//	{cwd}     working directory of the workspace
+//	{remote}  web URL of the origin remote of the git repository in {cwd},
+//	          e.g. https://github.com/stapelberg/wsmgr-for-i3
+//	{repo}    path of the origin remote, e.g. stapelberg/wsmgr-for-i3
+//	{branch}  currently checked out branch of the git repository in {cwd}
+//	          (the commit for a detached HEAD)
+//
+// Values are escaped for use in URL paths (slashes in {cwd}, {repo} and
+// {branch} are kept), except for {remote}, which is a URL itself. In the query
+// (after the ?), all values are escaped as query parameter values, e.g. &
+// becomes %26.
+func (p *placeholders) expand(tmpl string) (string, error) {
+	query := strings.IndexByte(tmpl, '?')
+	var (
+		result strings.Builder
+		last   int
+	)
+	for _, m := range placeholderRe.FindAllStringSubmatchIndex(tmpl, -1) {
+		result.WriteString(tmpl[last:m[0]])
+		last = m[1]
+		name := tmpl[m[2]:m[3]]
+		var (
+			val string
+			err error
+		)
+		switch name {
+		case "name":
+			val = p.workspace
+		case "cwd":
+			val = p.cwd
+		case "remote", "repo", "branch":
+			val, err = p.gitInfo(name)
+		default:
+			err = fmt.Errorf("unknown placeholder {%s}", name)
+		}
+		if err != nil {
+			return "", err
+		}
+		if val == "" {
+			return "", fmt.Errorf("placeholder {%s} is empty", name)
+		}
+		switch {
+		case query != -1 && m[0] > query:
+			val = url.QueryEscape(val)
+		case name == "name":
+			val = url.PathEscape(val)
+		case name != "remote":
+			val = escapePath(val)
+		}
+		result.WriteString(val)
+	}
+	result.WriteString(tmpl[last:])
+	return result.String(), nil
+}
+
+// escapePath escapes each slash-separated element of path for use in a URL
+// path, e.g. feat/x#1 becomes feat/x%231.
+func escapePath(path string) string {
+	parts := strings.Split(path, "/")
+	for idx, part := range parts {
+		parts[idx] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// gitInfo returns the remote, repo or branch of the git repository in p.cwd.
+func (p *placeholders) gitInfo(name string) (string, error) {
+	if p.git != nil {
+		return p.git[name], nil
+	}
+	if p.cwd == "" {
+		return "", fmt.Errorf("{%s}: no working directory configured", name)
+	}
+	git := func(args ...string) (string, error) {
+		cmd := exec.Command("git", append([]string{"-C", p.cwd}, args...)...)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%v: %v", cmd.Args, err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	remote, err := git("remote", "get-url", "origin")
+	if err != nil {
+		return "", err
+	}
+	branch, err := git("symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		// detached HEAD: use the commit instead
+		branch, err = git("rev-parse", "--short", "HEAD")
+		if err != nil {
+			return "", err
+		}
+	}
+	web, repo := webURL(remote)
+	p.git = map[string]string{
+		"remote": web,
+		"repo":   repo,
+		"branch": branch,
+	}
+	return p.git[name], nil
+}
+
+// scpLikeRe matches scp-like git remote URLs, e.g. git@github.com:user/repo.git
+var scpLikeRe = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.*)$`)
+
+// webURL converts a git remote URL (https://, ssh:// or scp-like) into the
+// https URL of the repository’s web page, and returns the repository path.
+func webURL(remote string) (web, repo string) {
+	var host, path string
+	if idx := strings.Index(remote, "://"); idx > -1 {
+		rest := remote[idx+len("://"):]
+		if at := strings.Index(rest, "@"); at > -1 && at < strings.Index(rest+"/", "/") {
+			rest = rest[at+1:] // strip user
+		}
+		parts := strings.SplitN(rest, "/", 2)
+		host = parts[0]
+		if len(parts) > 1 {
+			path = parts[1]
+		}
+		if remote[:idx] != "http" && remote[:idx] != "https" {
+			host = strings.Split(host, ":")[0] // strip ssh port
+		}
+	} else if m := scpLikeRe.FindStringSubmatch(remote); m != nil {
+		host, path = m[1], m[2]
+	} else {
+		return remote, ""
+	}
+	repo = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return "https://" + host + "/" + repo, repo
+}
+
+// readURLs reads the URL templates from fn (one per line, empty lines and lines
+// starting with # are skipped) and expands their placeholders. URLs whose
+// placeholders cannot be expanded are skipped.
+func readURLs(fn string, p *placeholders) ([]string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var urls []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		u, err := p.expand(line)
+		if err != nil {
+			log.Printf("%s: skipping %q: %v", fn, line, err)
+			continue
+		}
+		urls = append(urls, u)
+	}
+	return urls, scanner.Err()
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestExpand(t *testing.T) {
+	p := &placeholders{
+		workspace: "my & ws",
+		cwd:       "/home/michael/src/a b",
+		git: map[string]string{
+			"remote": "https://github.com/stapelberg/wsmgr-for-i3",
+			"repo":   "stapelberg/wsmgr-for-i3",
+			"branch": "feat/x#1",
+		},
+	}
+	for _, tt := range []struct {
+		tmpl string
+		want string
+	}{
+		{tmpl: "{remote}/tree/{branch}", want: "https://github.com/stapelberg/wsmgr-for-i3/tree/feat/x%231"},
+		{tmpl: "https://pkg.go.dev/github.com/{repo}", want: "https://pkg.go.dev/github.com/stapelberg/wsmgr-for-i3"},
+		{tmpl: "https://example.com/search?q={name}&x=1", want: "https://example.com/search?q=my+%26+ws&x=1"},
+		{tmpl: "file://{cwd}", want: "file:///home/michael/src/a%20b"},
+		{tmpl: "https://example.com/{name}/?q={name}&b={branch}", want: "https://example.com/my%20&%20ws/?q=my+%26+ws&b=feat%2Fx%231"},
+		{tmpl: "https://example.com/?repo={remote}", want: "https://example.com/?repo=https%3A%2F%2Fgithub.com%2Fstapelberg%2Fwsmgr-for-i3"},
+	} {
+		got, err := p.expand(tt.tmpl)
+		if err != nil {
+			t.Fatalf("expand(%q): %v", tt.tmpl, err)
+		}
+		if got != tt.want {
+			t.Errorf("expand(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+
+	for _, tmpl := range []string{"{unknown}", "{remote}"} {
+		p := &placeholders{workspace: "kint", git: map[string]string{}}
+		if _, err := p.expand(tmpl); err == nil {
+			t.Errorf("expand(%q) unexpectedly succeeded", tmpl)
+		}
+	}
+}
+
+func TestWebURL(t *testing.T) {
+	for _, tt := range []struct {
+		remote    string
+		web, repo string
+	}{
+		{"https://github.com/stapelberg/wsmgr-for-i3.git", "https://github.com/stapelberg/wsmgr-for-i3", "stapelberg/wsmgr-for-i3"},
+		{"git@github.com:stapelberg/wsmgr-for-i3.git", "https://github.com/stapelberg/wsmgr-for-i3", "stapelberg/wsmgr-for-i3"},
+		{"ssh://git@example.com:2222/team/repo", "https://example.com/team/repo", "team/repo"},
+		{"/srv/git/repo", "/srv/git/repo", ""},
+	} {
+		web, repo := webURL(tt.remote)
+		if web != tt.web || repo != tt.repo {
+			t.Errorf("webURL(%q) = %q, %q, want %q, %q", tt.remote, web, repo, tt.web, tt.repo)
+		}
+	}
+}
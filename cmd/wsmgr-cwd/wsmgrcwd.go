@@ -1,70 +1,278 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
+	"strconv"
+	"strings"
 	"syscall"
 
+	"github.com/google/renameio/v2"
+	"github.com/stapelberg/wsmgr-for-i3/internal/cwd"
+	"github.com/stapelberg/wsmgr-for-i3/internal/envfile"
+	"github.com/stapelberg/wsmgr-for-i3/internal/wm"
+	"github.com/stapelberg/wsmgr-for-i3/internal/wsname"
 	"go.i3wm.org/i3/v4"
 )
 
-var nameRe = regexp.MustCompile(`^\d+: (.*)`)
-
-func getWorkspaceName() string {
+// focused returns the focused workspace and the focused window (nil if the
+// workspace is empty).
+func focused() (ws *i3.Node, window *i3.Node) {
 	tree, err := i3.GetTree()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	ws := tree.Root.FindFocused(func(n *i3.Node) bool { return n.Type == i3.WorkspaceNode })
+	ws = tree.Root.FindFocused(func(n *i3.Node) bool { return n.Type == i3.WorkspaceNode })
 	if ws == nil {
 		log.Fatal("could not locate workspace")
 	}
-	name := ws.Name
-	if matches := nameRe.FindStringSubmatch(name); len(matches) > 1 {
-		name = matches[1]
+	window = ws.FindFocused(func(n *i3.Node) bool { return n.Focused && wm.IsWindow(n) })
+	return ws, window
+}
+
+// workspaceName returns the name of the configuration directory of workspace
+// ws, parsed according to the wsmgr name format (see package wsname).
+func workspaceName(configDir string, ws *i3.Node) (string, error) {
+	f, err := wsname.Load(filepath.Join(configDir, "wsmgr-for-i3", "name-format"))
+	if err != nil {
+		return "", err
+	}
+	// Like wsmgr, resolve names starting with a glyph (e.g. “– notes”) via
+	// the configured workspaces and their icons.
+	f = f.WithIcons(func(name string) (string, bool) {
+		if name == "" || strings.HasPrefix(name, ".") || strings.ContainsRune(name, '/') {
+			return "", false
+		}
+		dir := filepath.Join(configDir, "wsmgr-for-i3", name)
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			return "", false
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, "icon"))
+		if err != nil {
+			return "", true
+		}
+		if icon := strings.TrimSpace(string(b)); wsname.ValidIcon(icon) {
+			return icon, true
+		}
+		return "", true // no icon, or an image (which is not part of the name)
+	})
+	if p := f.Parse(ws.Name); p.Name != "" {
+		return p.Name, nil
 	}
-	return name
+	return ws.Name, nil // numbered workspace
 }
 
-func chdir() error {
+// workspaceDir returns the configuration directory of the current workspace,
+// i.e. ~/.config/wsmgr-for-i3/<name>, and its focused window.
+func workspaceDir() (string, *i3.Node, error) {
 	// get the current workspace’s name
-	workspaceName := getWorkspaceName()
+	ws, window := focused()
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", nil, err
+	}
+	workspaceName, err := workspaceName(configDir, ws)
+	if err != nil {
+		return "", nil, err
+	}
 	log.Printf("workspace name = %q", workspaceName)
 
-	// check if ~/.config/wsmgr-for-i3/<name>/cwd exists
-	configDir, err := os.UserConfigDir()
+	return filepath.Join(configDir, "wsmgr-for-i3", workspaceName), window, nil
+}
+
+// resolve returns the working directory of the workspace with configuration
+// directory dir, or the empty string if none is configured.
+func resolve(dir string, window *i3.Node) (string, error) {
+	r := &cwd.Resolver{
+		Dir: dir,
+	}
+	if window != nil {
+		r.TerminalPID = func() (int, error) {
+			pids, err := wm.NewPIDReader()
+			if err != nil {
+				return 0, err
+			}
+			defer pids.Close()
+			return pids.PID(window)
+		}
+	}
+	order, err := cwd.Order(r.Dir)
 	if err != nil {
+		return "", err
+	}
+	return r.Resolve(order)
+}
+
+func chdir(dir string, window *i3.Node) error {
+	target, err := resolve(dir, window)
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		return nil
+	}
+	if err := os.Chdir(target); err != nil {
 		return err
 	}
-	target, err := os.Readlink(filepath.Join(configDir, "wsmgr-for-i3", workspaceName, "cwd"))
+	return nil
+}
+
+// setenv applies the environment file of the workspace configuration
+// directory dir to the environment of this process.
+func setenv(dir string) error {
+	env, err := envfile.Apply(os.Environ(), filepath.Join(dir, "env"))
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
+		return err
+	}
+	for _, kv := range env {
+		idx := strings.IndexByte(kv, '=')
+		if err := os.Setenv(kv[:idx], kv[idx+1:]); err != nil {
+			return err
 		}
+	}
+	return nil
+}
+
+// printCwd prints the working directory of the current workspace, for use in
+// shell prompts and scripts.
+func printCwd() error {
+	dir, window, err := workspaceDir()
+	if err != nil {
 		return err
 	}
-	if err := os.Chdir(target); err != nil {
+	target, err := resolve(dir, window)
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		return fmt.Errorf("no working directory configured in %s", dir)
+	}
+	fmt.Println(target)
+	return nil
+}
+
+// setCwd atomically points the cwd symlink of the current workspace to target.
+func setCwd(target string) error {
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	st, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	if !st.IsDir() {
+		return fmt.Errorf("%s is not a directory", target)
+	}
+	dir, _, err := workspaceDir()
+	if err != nil {
+		return err
+	}
+	if _, err := strconv.ParseInt(filepath.Base(dir), 0, 64); err == nil {
+		return fmt.Errorf("workspace %s has no name (rename it first)", filepath.Base(dir))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := renameio.Symlink(target, filepath.Join(dir, "cwd")); err != nil {
 		return err
 	}
+	log.Printf("cwd of workspace %s set to %s", filepath.Base(dir), target)
 	return nil
 }
 
-func cwd() error {
-	if err := chdir(); err != nil {
+const shellInitPOSIX = `# Start new interactive shells in the working directory of the current
+# i3 workspace (see wsmgr-cwd). Only shells started in $HOME are changed.
+case $- in
+*i*)
+	if [ "$PWD" = "$HOME" ] && command -v wsmgr-cwd >/dev/null 2>&1; then
+		__wsmgr_cwd="$(wsmgr-cwd --print 2>/dev/null)" && cd "$__wsmgr_cwd"
+		unset __wsmgr_cwd
+	fi
+	;;
+esac
+`
+
+const shellInitFish = `# Start new interactive shells in the working directory of the current
+# i3 workspace (see wsmgr-cwd). Only shells started in $HOME are changed.
+if status is-interactive; and test "$PWD" = "$HOME"; and command -q wsmgr-cwd
+	set -l __wsmgr_cwd (wsmgr-cwd --print 2>/dev/null); and cd $__wsmgr_cwd
+end
+`
+
+// shellInit writes a snippet to w which starts interactive shells in the
+// workspace’s working directory. Usage:
+//
+//	eval "$(wsmgr-cwd --shell-init=bash)"   # in ~/.bashrc
+//	eval "$(wsmgr-cwd --shell-init=zsh)"    # in ~/.zshrc
+//	wsmgr-cwd --shell-init=fish | source    # in ~/.config/fish/config.fish
+func shellInit(w io.Writer, shell string) error {
+	switch shell {
+	case "bash", "zsh":
+		_, err := io.WriteString(w, shellInitPOSIX)
+		return err
+	case "fish":
+		_, err := io.WriteString(w, shellInitFish)
+		return err
+	default:
+		return fmt.Errorf("unsupported shell %q: syntax: --shell-init=bash|zsh|fish", shell)
+	}
+}
+
+func cwdMain() error {
+	var (
+		printDir = flag.Bool("print", false, "print the working directory of the current workspace")
+		setDir   = flag.Bool("set", false, "set the working directory of the current workspace to the specified directory (default: the current directory)")
+		shell    = flag.String("shell-init", "", "print a snippet for the specified shell (bash, zsh or fish) which starts interactive shells in the working directory of the current workspace")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "syntax: %s [--print | --set [dir] | --shell-init=bash|zsh|fish | <command>]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	// Parsing stops at the first non-flag argument, so flags of <command> are
+	// not interpreted.
+	flag.Parse()
+
+	switch {
+	case *printDir:
+		return printCwd()
+
+	case *setDir:
+		target := flag.Arg(0)
+		if target == "" {
+			target = "."
+		}
+		return setCwd(target)
+
+	case *shell != "":
+		return shellInit(os.Stdout, *shell)
+	}
+
+	dir, window, err := workspaceDir()
+	if err != nil {
+		return err
+	}
+	if err := chdir(dir, window); err != nil {
+		log.Print(err)
+	}
+	if err := setenv(dir); err != nil {
 		log.Print(err)
 	}
 
 	// check if we have something to run
-	if len(os.Args) < 2 {
+	if flag.NArg() < 1 {
 		log.Fatalf("no command to execute: syntax: %s <command>", os.Args[0])
 	}
 
 	// run the remaining command line args
-	args := os.Args[1:]
+	args := flag.Args()
 	full, err := exec.LookPath(args[0])
 	if err != nil {
 		return err
@@ -73,7 +281,8 @@ func cwd() error {
 }
 
 func main() {
-	if err := cwd(); err != nil {
+	wm.Init()
+	if err := cwdMain(); err != nil {
 		log.Fatal(err)
 	}
 }
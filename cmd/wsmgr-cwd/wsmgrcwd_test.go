@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stapelberg/wsmgr-for-i3/internal/wm/wmtest"
+	"go.i3wm.org/i3/v4"
+)
+
+// setTestenv sets the environment variable key to value for the duration of the
+// test (t.Setenv requires Go 1.17).
+func setTestenv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestSetCwd(t *testing.T) {
+	dir := t.TempDir()
+	setTestenv(t, "XDG_CONFIG_HOME", dir)
+	s := wmtest.NewServer(t)
+	s.Install()
+	s.AddWorkspace("1: kint")
+	s.AddWorkspace("2")
+
+	src := t.TempDir()
+	if err := setCwd(src); err != nil {
+		t.Fatal(err)
+	}
+	// Setting it again replaces the symlink.
+	newer := t.TempDir()
+	if err := setCwd(newer); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.Readlink(filepath.Join(dir, "wsmgr-for-i3", "kint", "cwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != newer {
+		t.Errorf("cwd symlink points to %q, want %q", got, newer)
+	}
+
+	fn := filepath.Join(src, "file")
+	if err := ioutil.WriteFile(fn, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := setCwd(fn); err == nil {
+		t.Errorf("setCwd(file) = nil error, want error")
+	}
+
+	s.Focus("2")
+	if err := setCwd(src); err == nil {
+		t.Errorf("setCwd() on a numbered workspace = nil error, want error")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "wsmgr-for-i3", "2")); !os.IsNotExist(err) {
+		t.Errorf("configuration directory created for a numbered workspace: %v", err)
+	}
+}
+
+func TestWorkspaceName(t *testing.T) {
+	configDir := t.TempDir()
+	music := filepath.Join(configDir, "wsmgr-for-i3", "music")
+	if err := os.MkdirAll(music, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(music, "icon"), []byte("♫\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		want string
+	}{
+		{name: "1: ♫ music", want: "music"},
+		// Glyphs are only parsed as the icon of workspaces with that icon.
+		{name: "2: – notes", want: "– notes"},
+		{name: "3: » inbox", want: "» inbox"},
+		{name: "4", want: "4"},
+	} {
+		got, err := workspaceName(configDir, &i3.Node{Name: tt.name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("workspaceName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShellInit(t *testing.T) {
+	for _, tt := range []struct {
+		shell string
+		want  string
+		err   bool
+	}{
+		{shell: "bash", want: shellInitPOSIX},
+		{shell: "zsh", want: shellInitPOSIX},
+		{shell: "fish", want: shellInitFish},
+		{shell: "tcsh", err: true},
+	} {
+		var buf bytes.Buffer
+		err := shellInit(&buf, tt.shell)
+		if (err != nil) != tt.err {
+			t.Errorf("shellInit(%q) = %v, want error %v", tt.shell, err, tt.err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("shellInit(%q) wrote %q, want %q", tt.shell, got, tt.want)
+		}
+	}
+}
+
+func TestShellInitPOSIX(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip(err)
+	}
+	home := t.TempDir()
+	target := t.TempDir()
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho " + target + "\n"
+	if err := ioutil.WriteFile(filepath.Join(bin, "wsmgr-cwd"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		desc string
+		args []string
+		dir  string
+		want string
+	}{
+		{
+			desc: "interactive",
+			args: []string{"-i"},
+			dir:  home,
+			want: target,
+		},
+
+		{
+			desc: "interactive, not started in $HOME",
+			args: []string{"-i"},
+			dir:  bin,
+			want: bin,
+		},
+
+		{
+			desc: "not interactive",
+			dir:  home,
+			want: home,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			cmd := exec.Command(sh, append(tt.args, "-c", shellInitPOSIX+"pwd")...)
+			cmd.Dir = tt.dir
+			cmd.Env = []string{
+				"HOME=" + home,
+				"PWD=" + tt.dir,
+				"PATH=" + bin + ":/usr/bin:/bin",
+				"ENV=", // do not read a startup file
+			}
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(string(out)); got != tt.want {
+				t.Errorf("working directory = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
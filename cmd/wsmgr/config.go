@@ -0,0 +1,520 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/renameio/v2"
+	"github.com/stapelberg/wsmgr-for-i3/internal/cwd"
+	"github.com/stapelberg/wsmgr-for-i3/internal/envfile"
+	"github.com/stapelberg/wsmgr-for-i3/internal/wsname"
+)
+
+// settingFiles are the files in a workspace configuration directory which
+// configure settings (as opposed to executables which are started).
+var settingFiles = map[string]bool{
+	"cwd":             true,
+	"cwd-glob":        true,
+	"cwd-order":       true,
+	"env":             true,
+	"chrome-rewindow": true,
+	"urls":            true,
+	"pinned":          true,
+	"icon":            true,
+	"color":           true,
+}
+
+// configInfo summarizes a workspace configuration directory, so that users
+// can see what loading the workspace will do.
+type configInfo struct {
+	Name           string
+	Cwd            string   // resolved working directory (without the terminal source)
+	ChromeRewindow string   // bookmark folder
+	URLs           int      // number of lines in the urls file
+	Executables    []string // file names
+	Pinned         bool
+	PinnedNumber   int64  // 0 if the workspace keeps its current number
+	Icon           string // glyph, see readIcon
+	IconImage      string // path of an image, see readIcon
+	Color          string
+	LastLoad       time.Time
+	Warnings       []string // broken configuration
+}
+
+// inspectConfig reads the workspace configuration directory of workspace name.
+// Problems are reported as Warnings, an error is only returned when the
+// directory cannot be read.
+func inspectConfig(name string) (configInfo, error) {
+	info := configInfo{Name: name}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return info, err
+	}
+	dir := filepath.Join(configDir, "wsmgr-for-i3", name)
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return info, err
+	}
+	warnf := func(format string, args ...interface{}) {
+		info.Warnings = append(info.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	dangling := false
+	if fi, err := os.Lstat(filepath.Join(dir, "cwd")); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if _, err := os.Stat(filepath.Join(dir, "cwd")); err != nil {
+			target, _ := os.Readlink(filepath.Join(dir, "cwd"))
+			warnf("cwd: dangling symlink to %s", target)
+			dangling = true
+		}
+	}
+	if order, err := cwd.Order(dir); err != nil {
+		warnf("%v", err)
+	} else {
+		r := &cwd.Resolver{Dir: dir}
+		info.Cwd, err = r.Resolve(order)
+		if err != nil && !dangling {
+			warnf("%v", err)
+		}
+	}
+	if _, err := envfile.Apply(nil, filepath.Join(dir, "env")); err != nil {
+		warnf("%v", err)
+	}
+
+	for _, fi := range fis {
+		path := filepath.Join(dir, fi.Name())
+		switch fi.Name() {
+		case "chrome-rewindow":
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				warnf("%v", err)
+				continue
+			}
+			info.ChromeRewindow = strings.TrimSpace(strings.SplitN(strings.TrimSpace(string(b)), "\n", 2)[0])
+			if info.ChromeRewindow == "" {
+				warnf("chrome-rewindow: no bookmark folder specified")
+			}
+			continue
+
+		case "pinned":
+			info.Pinned = true
+			if info.PinnedNumber, err = readPinned(path); err != nil {
+				warnf("%v", err)
+			}
+			continue
+
+		case "icon":
+			if info.Icon, info.IconImage, err = readIcon(path); err != nil {
+				warnf("%v", err)
+			}
+			continue
+
+		case "color":
+			if info.Color, err = readColor(path); err != nil {
+				warnf("%v", err)
+			}
+			continue
+
+		case "urls":
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				warnf("%v", err)
+				continue
+			}
+			for _, line := range strings.Split(string(b), "\n") {
+				if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+					info.URLs++
+				}
+			}
+			continue
+		}
+		if settingFiles[fi.Name()] {
+			continue
+		}
+
+		if fi.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(path)
+			if err != nil {
+				warnf("%s: dangling symlink", fi.Name())
+				continue
+			}
+			fi = target
+		}
+		if fi.IsDir() {
+			continue
+		}
+		if fi.Mode()&0100 != 0 {
+			info.Executables = append(info.Executables, fi.Name())
+			continue
+		}
+		if isScript(path) {
+			warnf("%s: script is not executable (chmod +x)", fi.Name())
+		}
+	}
+
+	loads, err := readLastLoads()
+	if err != nil {
+		warnf("%v", err)
+	}
+	info.LastLoad = loads[name]
+	return info, nil
+}
+
+// isScript returns whether fn starts with a #! line.
+func isScript(fn string) bool {
+	f, err := os.Open(fn)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var buf [2]byte
+	n, _ := f.Read(buf[:])
+	return bytes.Equal(buf[:n], []byte("#!"))
+}
+
+// Summary returns a one-line description of what loading the workspace does.
+func (i configInfo) Summary() string {
+	var parts []string
+	if i.Cwd != "" {
+		parts = append(parts, "in "+tildify(i.Cwd))
+	}
+	if len(i.Executables) > 0 {
+		parts = append(parts, plural(len(i.Executables), "executable"))
+	}
+	if i.ChromeRewindow != "" {
+		parts = append(parts, "bookmarks "+i.ChromeRewindow)
+	}
+	if i.URLs > 0 {
+		parts = append(parts, plural(i.URLs, "URL"))
+	}
+	if i.PinnedNumber > 0 {
+		parts = append(parts, fmt.Sprintf("pinned to %d", i.PinnedNumber))
+	} else if i.Pinned {
+		parts = append(parts, "pinned")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// Details returns a multi-line description of the workspace configuration.
+func (i configInfo) Details() string {
+	var lines []string
+	for _, w := range i.Warnings {
+		lines = append(lines, "⚠ "+w)
+	}
+	if i.Cwd != "" {
+		lines = append(lines, "working directory: "+tildify(i.Cwd))
+	}
+	if i.ChromeRewindow != "" {
+		lines = append(lines, "bookmark folder: "+i.ChromeRewindow)
+	}
+	if i.URLs > 0 {
+		lines = append(lines, fmt.Sprintf("URLs: %d", i.URLs))
+	}
+	if len(i.Executables) > 0 {
+		lines = append(lines, "executables: "+strings.Join(i.Executables, ", "))
+	}
+	if i.PinnedNumber > 0 {
+		lines = append(lines, fmt.Sprintf("pinned: keeps number %d when reordering", i.PinnedNumber))
+	} else if i.Pinned {
+		lines = append(lines, "pinned: keeps its number when reordering")
+	}
+	if i.Icon != "" {
+		lines = append(lines, "icon: "+i.Icon)
+	} else if i.IconImage != "" {
+		lines = append(lines, "icon: image (only shown in wsmgr)")
+	}
+	if i.Color != "" {
+		lines = append(lines, "color: "+i.Color)
+	}
+	lines = append(lines, "last loaded: "+i.LastLoadString())
+	return strings.Join(lines, "\n")
+}
+
+// LastLoadString returns when the workspace was last loaded, for display.
+func (i configInfo) LastLoadString() string {
+	if i.LastLoad.IsZero() {
+		return "never"
+	}
+	if time.Since(i.LastLoad) < 24*time.Hour {
+		return i.LastLoad.Format("15:04")
+	}
+	return i.LastLoad.Format("2006-01-02")
+}
+
+// tildify replaces the home directory prefix of path with ~.
+func tildify(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if strings.HasPrefix(path, home+"/") {
+		return "~" + strings.TrimPrefix(path, home)
+	}
+	return path
+}
+
+// lastLoadsFile returns the path of the file in which the time at which each
+// workspace was last loaded is stored. This is state, not configuration, so it
+// is stored in the cache directory.
+func lastLoadsFile() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "wsmgr-for-i3", "last-load.json"), nil
+}
+
+func readLastLoads() (map[string]time.Time, error) {
+	fn, err := lastLoadsFile()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var loads map[string]time.Time
+	if err := json.Unmarshal(b, &loads); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return loads, nil
+}
+
+// recordLoad stores the current time as the last load time of workspace name.
+func recordLoad(name string) error {
+	loads, err := readLastLoads()
+	if err != nil {
+		return err
+	}
+	if loads == nil {
+		loads = make(map[string]time.Time)
+	}
+	loads[name] = time.Now()
+	fn, err := lastLoadsFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(loads)
+	if err != nil {
+		return err
+	}
+	return renameio.WriteFile(fn, b, 0644)
+}
+
+// loadNameFormat loads the workspace name format from
+// ~/.config/wsmgr-for-i3/name-format (see package wsname).
+func loadNameFormat() (*wsname.Format, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := wsname.Load(filepath.Join(configDir, "wsmgr-for-i3", "name-format"))
+	if err != nil {
+		return nil, err
+	}
+	return f.WithIcons(lookupWorkspace), nil
+}
+
+// lookupWorkspace returns the icon glyph configured for workspace name (if any)
+// and whether name is a configured workspace, see wsname.Format.WithIcons.
+func lookupWorkspace(name string) (icon string, configured bool) {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsRune(name, '/') {
+		return "", false // not a configuration directory, see configuredWorkspaces
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", false
+	}
+	fi, err := os.Stat(filepath.Join(configDir, "wsmgr-for-i3", name))
+	if err != nil || !fi.IsDir() {
+		return "", false
+	}
+	glyph, _ := workspaceIcon(name)
+	return glyph, true
+}
+
+// readIcon reads the icon file fn, which contains either a glyph (e.g. a Font
+// Awesome code point), which is rendered into the i3 workspace name, or an
+// image (or is a symlink to an image), which only wsmgr displays. For images,
+// fn is returned as image.
+func readIcon(fn string) (glyph, image string, _ error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return "", "", err
+	}
+	s := strings.TrimSpace(string(b))
+	if s == "" {
+		return "", "", fmt.Errorf("%s: empty icon", fn)
+	}
+	if isImage(fn, b) {
+		return "", fn, nil
+	}
+	if !wsname.ValidIcon(s) {
+		return "", "", fmt.Errorf("%s: icon %q is not a glyph (ASCII characters, letters, digits and spaces would be mistaken for the workspace name)", fn, s)
+	}
+	return s, "", nil
+}
+
+// isImage returns whether the icon file fn with contents b is an image, judging
+// by its contents or (if it is a symlink) by the extension of its target.
+func isImage(fn string, b []byte) bool {
+	if strings.HasPrefix(http.DetectContentType(b), "image/") {
+		return true
+	}
+	// DetectContentType does not recognize SVG. Glyphs cannot start with <
+	// (see wsname.ValidIcon), so this must be XML.
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("<")) {
+		return true
+	}
+	target, err := filepath.EvalSymlinks(fn)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(target)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".bmp", ".ico", ".webp", ".svg", ".svgz", ".xpm":
+		return true
+	}
+	return false
+}
+
+// readColor reads the color file fn, which contains a color like #ff8800 or a
+// color name like orange.
+func readColor(fn string) (string, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return "", err
+	}
+	s := strings.TrimSpace(string(b))
+	if !wsname.ValidColor(s) {
+		return "", fmt.Errorf("%s: invalid color %q (want e.g. #ff8800 or orange)", fn, s)
+	}
+	return s, nil
+}
+
+// workspaceIcon returns the icon configured for workspace name: a glyph or the
+// path of an image (see readIcon). Both are empty if no valid icon is
+// configured.
+func workspaceIcon(name string) (glyph, image string) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", ""
+	}
+	glyph, image, err = readIcon(filepath.Join(configDir, "wsmgr-for-i3", name, "icon"))
+	if err != nil && !os.IsNotExist(err) {
+		log.Print(err)
+	}
+	return glyph, image
+}
+
+// workspaceColor returns the color configured for workspace name, or the empty
+// string if no valid color is configured.
+func workspaceColor(name string) string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	color, err := readColor(filepath.Join(configDir, "wsmgr-for-i3", name, "color"))
+	if err != nil && !os.IsNotExist(err) {
+		log.Print(err)
+	}
+	return color
+}
+
+// readPinned reads the pinned file fn, which is either empty or contains the
+// number of the workspace (0 is returned for empty files).
+func readPinned(fn string) (int64, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return 0, err
+	}
+	s := strings.TrimSpace(string(b))
+	if s == "" {
+		return 0, nil
+	}
+	num, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || num < 1 {
+		return 0, fmt.Errorf("%s: invalid workspace number %q", fn, s)
+	}
+	return num, nil
+}
+
+// pinnedWorkspaces returns the configured workspaces which contain a pinned
+// file, which makes them keep their number when reordering workspaces. The
+// value is the number from the pinned file, or 0 if the workspace keeps its
+// current number. Invalid pinned files are logged and skipped (inspectConfig
+// shows them as a warning).
+func pinnedWorkspaces() (map[string]int64, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	names, err := configuredWorkspaces()
+	if err != nil {
+		return nil, err
+	}
+	pinned := make(map[string]int64)
+	for _, name := range names {
+		num, err := readPinned(filepath.Join(configDir, "wsmgr-for-i3", name, "pinned"))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Print(err)
+			}
+			continue
+		}
+		pinned[name] = num
+	}
+	return pinned, nil
+}
+
+// configuredWorkspaces returns the names of all workspace configuration
+// directories, sorted by name.
+func configuredWorkspaces() ([]string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	fis, err := ioutil.ReadDir(filepath.Join(configDir, "wsmgr-for-i3"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, fi := range fis {
+		if !fi.Mode().IsDir() {
+			continue
+		}
+		if strings.HasPrefix(fi.Name(), ".") {
+			continue // e.g. temporary directory of duplicateConfig
+		}
+		names = append(names, fi.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
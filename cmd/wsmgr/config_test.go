@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadIcon(t *testing.T) {
+	dir := t.TempDir()
+	jpeg, err := ioutil.ReadFile("../../img/2021-10-21-i3bar-kint-small.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"/>`
+	for _, tt := range []struct {
+		desc      string
+		content   string
+		target    string // if non-empty, the icon file is a symlink to it
+		wantGlyph string
+		wantImage bool
+		wantErr   bool
+	}{
+		{desc: "glyph", content: "♫\n", wantGlyph: "♫"},
+		{desc: "long glyph", content: "★★★★★★★★★★★★", wantGlyph: "★★★★★★★★★★★★"},
+		{desc: "image", content: string(jpeg), wantImage: true},
+		{desc: "small image", content: string(jpeg[:16]), wantImage: true},
+		{desc: "svg", content: svg, wantImage: true},
+		{desc: "svg without declaration", content: "<svg/>", wantImage: true},
+		{desc: "symlink to svg", content: "not xml", target: "music.svg", wantImage: true},
+		{desc: "symlink to glyph", content: "♫", target: "music.txt", wantGlyph: "♫"},
+		{desc: "letters", content: "music", wantErr: true},
+		{desc: "empty", content: "\n", wantErr: true},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			fn := filepath.Join(dir, tt.desc, "icon")
+			if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+				t.Fatal(err)
+			}
+			target := fn
+			if tt.target != "" {
+				target = filepath.Join(dir, tt.desc, tt.target)
+				if err := os.Symlink(tt.target, fn); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := ioutil.WriteFile(target, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			glyph, image, err := readIcon(fn)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readIcon = %v, want error: %v", err, tt.wantErr)
+			}
+			if glyph != tt.wantGlyph {
+				t.Errorf("readIcon: glyph = %q, want %q", glyph, tt.wantGlyph)
+			}
+			if got := image != ""; got != tt.wantImage {
+				t.Errorf("readIcon: image = %q, want image: %v", image, tt.wantImage)
+			}
+		})
+	}
+}
+
+func TestPinnedWorkspaces(t *testing.T) {
+	configDir := withConfigDir(t)
+	for name, content := range map[string]string{
+		"www":  "",
+		"kint": "7\n",
+		"mail": "seven",
+		"chat": "0",
+	} {
+		dir := filepath.Join(configDir, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "pinned"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(configDir, "notes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid pinned files are skipped instead of failing, so that
+	// reordering workspaces still works.
+	pinned, err := pinnedWorkspaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int64{"www": 0, "kint": 7}; !reflect.DeepEqual(pinned, want) {
+		t.Errorf("pinnedWorkspaces() = %v, want %v", pinned, want)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/renameio/v2"
+	"github.com/stapelberg/wsmgr-for-i3/internal/cwd"
+)
+
+// The following functions modify workspace configuration directories. Each
+// modification is atomic: files are replaced via rename(2), so that a
+// concurrently loading wsmgr never sees partially written configuration.
+
+// configPath returns the configuration directory of workspace name.
+func configPath(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return "", fmt.Errorf("invalid workspace name %q", name)
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "wsmgr-for-i3", name), nil
+}
+
+// createConfig creates an empty configuration directory for workspace name.
+func createConfig(name string) error {
+	dir, err := configPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	return os.Mkdir(dir, 0755)
+}
+
+// renameConfig renames the configuration directory of workspace oldName.
+func renameConfig(oldName, newName string) error {
+	oldDir, err := configPath(oldName)
+	if err != nil {
+		return err
+	}
+	newDir, err := configPath(newName)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(newDir); err == nil {
+		return fmt.Errorf("workspace %q already exists", newName)
+	}
+	return os.Rename(oldDir, newDir)
+}
+
+// duplicateConfig copies the configuration directory of workspace src to dst.
+// Files are copied into a temporary directory, which is then renamed to dst.
+func duplicateConfig(src, dst string) error {
+	srcDir, err := configPath(src)
+	if err != nil {
+		return err
+	}
+	dstDir, err := configPath(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dstDir); err == nil {
+		return fmt.Errorf("workspace %q already exists", dst)
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(dstDir), ".tmp-"+dst)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp) // no-op after the rename
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return err
+	}
+	fis, err := ioutil.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		from := filepath.Join(srcDir, fi.Name())
+		to := filepath.Join(tmp, fi.Name())
+		if fi.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(from)
+			if err != nil {
+				return err
+			}
+			if !filepath.IsAbs(target) {
+				// keep pointing to the same file
+				target = filepath.Join(srcDir, target)
+			}
+			if err := os.Symlink(target, to); err != nil {
+				return err
+			}
+			continue
+		}
+		if !fi.Mode().IsRegular() {
+			continue // e.g. directories
+		}
+		b, err := ioutil.ReadFile(from)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(to, b, fi.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp, dstDir)
+}
+
+// deleteConfig deletes the configuration directory of workspace name. The
+// directory is moved into a uniquely named temporary directory (so that
+// leftovers of an interrupted delete do not get in the way) before its
+// contents are deleted.
+func deleteConfig(name string) error {
+	dir, err := configPath(name)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(dir), ".deleted-"+name)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(dir, filepath.Join(tmp, name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.RemoveAll(tmp)
+}
+
+// configuredCwd returns the working directory configured in the cwd file of
+// workspace name, or the empty string.
+func configuredCwd(name string) (string, error) {
+	dir, err := configPath(name)
+	if err != nil {
+		return "", err
+	}
+	return cwd.FromConfig(dir)
+}
+
+// setConfigCwd points the cwd symlink of workspace name to target, or removes
+// it if target is empty.
+func setConfigCwd(name, target string) error {
+	dir, err := configPath(name)
+	if err != nil {
+		return err
+	}
+	fn := filepath.Join(dir, "cwd")
+	if target == "" {
+		if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return renameio.Symlink(target, fn)
+}
+
+// configuredBookmarkFolder returns the bookmark folder configured in the
+// chrome-rewindow file of workspace name, or the empty string.
+func configuredBookmarkFolder(name string) (string, error) {
+	dir, err := configPath(name)
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "chrome-rewindow"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(strings.SplitN(strings.TrimSpace(string(b)), "\n", 2)[0]), nil
+}
+
+// setBookmarkFolder replaces the bookmark folder in the chrome-rewindow file
+// of workspace name, keeping any further lines (flags). An empty folder
+// removes the file.
+func setBookmarkFolder(name, folder string) error {
+	dir, err := configPath(name)
+	if err != nil {
+		return err
+	}
+	fn := filepath.Join(dir, "chrome-rewindow")
+	if folder == "" {
+		if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	var flags string
+	if b, err := ioutil.ReadFile(fn); err == nil {
+		parts := strings.SplitN(strings.TrimSpace(string(b)), "\n", 2)
+		if len(parts) > 1 {
+			flags = parts[1] + "\n"
+		}
+	}
+	return renameio.WriteFile(fn, []byte(folder+"\n"+flags), 0644)
+}
+
+// bookmarkFolders returns the paths of all bookmark folders, as listed by
+// wsmgr-chrome-rewindow -list.
+func bookmarkFolders() ([]string, error) {
+	cmd := exec.Command("wsmgr-chrome-rewindow", "-list")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", cmd.Args, err)
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+}
+
+// configuredPinned returns whether workspace name contains a pinned file.
+func configuredPinned(name string) (bool, error) {
+	dir, err := configPath(name)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "pinned")); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// setPinned creates (if pinned is true) or removes the pinned file of
+// workspace name. An existing pinned file (which might contain a number) is
+// kept.
+func setPinned(name string, pinned bool) error {
+	dir, err := configPath(name)
+	if err != nil {
+		return err
+	}
+	fn := filepath.Join(dir, "pinned")
+	if !pinned {
+		if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if _, err := os.Lstat(fn); err == nil {
+		return nil
+	}
+	return renameio.WriteFile(fn, nil, 0644)
+}
+
+// executableEntry is a file in a workspace configuration directory which is
+// (or can be made) executable.
+type executableEntry struct {
+	Name       string
+	Executable bool
+	Symlink    bool // executable bits cannot be changed, see setExecutable
+}
+
+// configuredExecutables returns the files of workspace name which are not
+// settings, i.e. executables (or scripts which lack the executable bit).
+func configuredExecutables(name string) ([]executableEntry, error) {
+	dir, err := configPath(name)
+	if err != nil {
+		return nil, err
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []executableEntry
+	for _, fi := range fis {
+		if settingFiles[fi.Name()] {
+			continue
+		}
+		symlink := fi.Mode()&os.ModeSymlink != 0
+		if symlink {
+			if target, err := os.Stat(filepath.Join(dir, fi.Name())); err == nil {
+				fi = target
+			}
+		}
+		if fi.IsDir() {
+			continue
+		}
+		entries = append(entries, executableEntry{
+			Name:       fi.Name(),
+			Executable: fi.Mode()&0100 != 0,
+			Symlink:    symlink,
+		})
+	}
+	return entries, nil
+}
+
+// addExecutable adds the program src to workspace name, as a symlink or (if
+// copy is true) as a copy. Existing files are not replaced.
+func addExecutable(name, src string, copy bool) error {
+	dir, err := configPath(name)
+	if err != nil {
+		return err
+	}
+	fn := filepath.Join(dir, filepath.Base(src))
+	if settingFiles[filepath.Base(src)] {
+		return fmt.Errorf("%s: file name is reserved for a setting", filepath.Base(src))
+	}
+	if _, err := os.Lstat(fn); err == nil {
+		return fmt.Errorf("%s: workspace %q already contains a file of this name", filepath.Base(src), name)
+	}
+	if !copy {
+		return renameio.Symlink(src, fn)
+	}
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return renameio.WriteFile(fn, b, fi.Mode().Perm())
+}
+
+// removeExecutable removes the file from workspace name.
+func removeExecutable(name, file string) error {
+	dir, err := configPath(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(dir, file))
+}
+
+// setExecutable sets or clears the executable bits of the file in workspace
+// name. The executable bits of symlinks are those of the file they link to,
+// which is not part of the configuration (e.g. a program in /usr/bin), so they
+// cannot be changed.
+func setExecutable(name, file string, executable bool) error {
+	dir, err := configPath(name)
+	if err != nil {
+		return err
+	}
+	fn := filepath.Join(dir, file)
+	lfi, err := os.Lstat(fn)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return err
+	}
+	mode := fi.Mode().Perm()
+	if (mode&0100 != 0) == executable {
+		return nil
+	}
+	if lfi.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%s: not changing the executable bits of the symlink target", file)
+	}
+	if executable {
+		// like chmod +x: set the executable bit where the read bit is set
+		mode |= (mode & 0444) >> 2
+	} else {
+		mode &^= 0111
+	}
+	return os.Chmod(fn, mode)
+}
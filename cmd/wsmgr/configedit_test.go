@@ -0,0 +1,147 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDeleteConfig(t *testing.T) {
+	configDir := withConfigDir(t)
+	// A leftover of an interrupted delete of the same workspace.
+	if err := os.MkdirAll(filepath.Join(configDir, ".deleted-kint", "kint"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := createConfig("kint"); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(configDir, "kint", "urls"), []byte("https://example.com\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := deleteConfig("kint"); err != nil {
+			t.Fatalf("deleteConfig (#%d): %v", i+1, err)
+		}
+		if _, err := os.Lstat(filepath.Join(configDir, "kint")); !os.IsNotExist(err) {
+			t.Fatalf("deleteConfig (#%d): directory still exists (err = %v)", i+1, err)
+		}
+	}
+
+	fis, err := ioutil.ReadDir(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 1 || fis[0].Name() != ".deleted-kint" {
+		var names []string
+		for _, fi := range fis {
+			names = append(names, fi.Name())
+		}
+		t.Errorf("config directory contains %q, want only the leftover .deleted-kint", names)
+	}
+
+	if err := deleteConfig("nonexistent"); err == nil {
+		t.Errorf("deleteConfig(nonexistent) unexpectedly succeeded")
+	}
+}
+
+func TestDuplicateConfig(t *testing.T) {
+	configDir := withConfigDir(t)
+	if err := createConfig("kint"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(configDir, "kint", "pinned"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../src/kint", filepath.Join(configDir, "kint", "cwd")); err != nil {
+		t.Fatal(err)
+	}
+	if err := duplicateConfig("kint", "kint2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(configDir, "kint2", "pinned")); err != nil {
+		t.Error(err)
+	}
+	target, err := os.Readlink(filepath.Join(configDir, "kint2", "cwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(configDir, "kint", "../../src/kint"); target != want {
+		t.Errorf("cwd symlink of the duplicate points to %q, want %q", target, want)
+	}
+	if err := duplicateConfig("kint", "kint2"); err == nil {
+		t.Errorf("duplicateConfig onto an existing workspace unexpectedly succeeded")
+	}
+}
+
+func TestExecutables(t *testing.T) {
+	configDir := withConfigDir(t)
+	if err := createConfig("kint"); err != nil {
+		t.Fatal(err)
+	}
+	src := t.TempDir()
+	for _, name := range []string{"editor", "terminal"} {
+		if err := ioutil.WriteFile(filepath.Join(src, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(filepath.Join(src, name), 0755); err != nil { // regardless of umask
+			t.Fatal(err)
+		}
+	}
+	if err := addExecutable("kint", filepath.Join(src, "editor"), true /* copy */); err != nil {
+		t.Fatal(err)
+	}
+	if err := addExecutable("kint", filepath.Join(src, "terminal"), false /* copy */); err != nil {
+		t.Fatal(err)
+	}
+
+	// Existing files are not replaced.
+	other := filepath.Join(t.TempDir(), "editor")
+	if err := ioutil.WriteFile(other, []byte("#!/bin/sh\necho other\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := addExecutable("kint", other, true /* copy */); err == nil {
+		t.Errorf("addExecutable onto an existing file unexpectedly succeeded")
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(configDir, "kint", "editor")); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "#!/bin/sh\n" {
+		t.Errorf("existing file replaced, contents = %q", b)
+	}
+
+	entries, err := configuredExecutables("kint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []executableEntry{
+		{Name: "editor", Executable: true},
+		{Name: "terminal", Executable: true, Symlink: true},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("configuredExecutables = %+v, want %+v", entries, want)
+	}
+
+	if err := setExecutable("kint", "editor", false); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(filepath.Join(configDir, "kint", "editor")); err != nil {
+		t.Fatal(err)
+	} else if got := fi.Mode().Perm(); got != 0644 {
+		t.Errorf("mode after clearing the executable bits = %v, want %v", got, os.FileMode(0644))
+	}
+
+	// The target of a symlink is left alone.
+	if err := setExecutable("kint", "terminal", true); err != nil {
+		t.Errorf("setExecutable(symlink, unchanged) = %v", err)
+	}
+	if err := setExecutable("kint", "terminal", false); err == nil {
+		t.Errorf("setExecutable(symlink) unexpectedly succeeded")
+	}
+	if fi, err := os.Stat(filepath.Join(src, "terminal")); err != nil {
+		t.Fatal(err)
+	} else if got := fi.Mode().Perm(); got != 0755 {
+		t.Errorf("mode of the symlink target = %v, want %v", got, os.FileMode(0755))
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// wsmgr is single-instance per user and X11 display: the running instance
+// listens on a control socket, and further invocations send it a
+// controlMessage instead of opening another window.
+
+// controlMessage is sent (JSON-encoded, one per connection) from a new wsmgr
+// invocation to the running instance.
+type controlMessage struct {
+	// FocusSearch focuses the search of the configured workspaces list.
+	FocusSearch bool `json:"focus_search,omitempty"`
+
+	// Select selects the (open or configured) workspace of this name.
+	Select string `json:"select,omitempty"`
+}
+
+// toggle returns whether the message toggles the window. Messages which open
+// the window in a specific mode always show it.
+func (m controlMessage) toggle() bool {
+	return !m.FocusSearch && m.Select == ""
+}
+
+// controlSocketPath returns the path of the control socket for the current
+// user and X11 display.
+func controlSocketPath() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("wsmgr-for-i3-%d", os.Getuid()))
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", err
+		}
+	}
+	display := os.Getenv("DISPLAY")
+	if display == "" {
+		display = os.Getenv("WAYLAND_DISPLAY") // sway without Xwayland
+	}
+	if display == "" {
+		display = "default"
+	}
+	// e.g. /private/tmp/com.apple.launchd.xyz/org.xquartz:0 on macOS
+	display = strings.ReplaceAll(display, "/", "_")
+	return filepath.Join(dir, "wsmgr-for-i3-"+display+".sock"), nil
+}
+
+// sendControl sends msg to the running instance. It returns false if no
+// instance is running.
+func sendControl(path string, msg controlMessage) (bool, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return false, nil // no running instance (or a stale socket)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(msg); err != nil {
+		return true, err
+	}
+	// Wait for the running instance to acknowledge the message, so that
+	// key bindings cannot overtake each other.
+	if _, err := bufio.NewReader(conn).ReadString('\n'); err != nil {
+		return true, fmt.Errorf("reading reply from %s: %v", path, err)
+	}
+	return true, nil
+}
+
+// claimControl makes this process the running instance, unless another
+// instance is running already, in which case msg is sent to it and a nil
+// listener is returned. Otherwise, the returned listener is bound to the
+// control socket (replacing a stale socket of an instance which did not exit
+// cleanly).
+//
+// A lock file serializes concurrent invocations (e.g. from pressing a key
+// binding twice in quick succession), so that only one of them starts an
+// instance and the others reach it via the control socket.
+func claimControl(path string, msg controlMessage) (net.Listener, error) {
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Close() // releases the lock
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return nil, fmt.Errorf("locking %s: %v", lock.Name(), err)
+	}
+	if running, err := sendControl(path, msg); err != nil {
+		return nil, err
+	} else if running {
+		return nil, nil // the running instance handles msg
+	}
+	// The socket (if any) does not accept connections, so it is stale.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
+
+// serveControl calls handle for each message received on the control socket
+// ln. handle is called from a separate goroutine.
+func serveControl(ln net.Listener, handle func(controlMessage)) {
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return // listener closed
+			}
+			var msg controlMessage
+			if err := json.NewDecoder(conn).Decode(&msg); err != nil {
+				log.Printf("control socket: %v", err)
+				conn.Close()
+				continue
+			}
+			handle(msg)
+			conn.Write([]byte("ok\n"))
+			conn.Close()
+		}
+	}()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestClaimControl(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "wsmgr.sock")
+
+	// Concurrent invocations: exactly one becomes the running instance, the
+	// others send their message to it.
+	const invocations = 5
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		listeners []net.Listener
+		received  = make(chan controlMessage, invocations)
+	)
+	for i := 0; i < invocations; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ln, err := claimControl(sock, controlMessage{Select: "kint"})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ln == nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			listeners = append(listeners, ln)
+			serveControl(ln, func(msg controlMessage) { received <- msg })
+		}()
+	}
+	wg.Wait()
+	if len(listeners) != 1 {
+		t.Fatalf("%d instances claimed the control socket, want 1", len(listeners))
+	}
+	defer listeners[0].Close()
+	for i := 0; i < invocations-1; i++ {
+		if msg := <-received; msg.Select != "kint" {
+			t.Errorf("received %+v, want Select: kint", msg)
+		}
+	}
+}
+
+func TestClaimControlStaleSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "wsmgr.sock")
+	// A socket left behind by an instance which did not exit cleanly.
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: sock, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := claimControl(sock, controlMessage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ln == nil {
+		t.Fatalf("claimControl did not replace the stale socket")
+	}
+	ln.Close()
+}
@@ -1,50 +1,128 @@
+//go:build cgo
+// +build cgo
+
 package main
 
-const css = `
-#win,
-#grid,
-#icon,
-#title,
-#message,
-#btnunlock {
+// The wsmgr window consists of the following named widgets, which style.css
+// can refer to:
+//
+//	#win                  the wsmgr window
+//	#workspaces           list of open workspaces
+//	#add-workspace        “add workspace” button
+//	#configure-workspace  “configure workspace…” button
+//	#loader               list of configured workspaces
+//	#config-buttons       box with the “new/edit configuration…” buttons
+//	#configeditor         configuration editor dialog
+//
+// GTK does not support styling individual rows of a list via CSS. Instead, the
+// style of rows in a given state is taken from a (never shown) label named
+// after the state:
+//
+//	#wsmgr-row-focused    focused workspace
+//	#wsmgr-row-visible    workspace visible on another output
+//	#wsmgr-row-urgent     urgent workspace
+//	#wsmgr-row-loaded     configured workspace which is currently open
+//	#wsmgr-row-unloaded   configured workspace which is not open
+//	#wsmgr-row-output     output header rows of the open workspaces list
+//
+// Of these labels, the color, background-color and font-weight properties are
+// applied to the rows (see rowStyle), unless they are the same as for the
+// #wsmgr-row label, which is not styled for any state. Output header rows are
+// only shown when workspaces are open on more than one output.
+
+// defaultCSS is loaded for all themes. Its colors are derived from the user’s
+// GTK theme.
+const defaultCSS = `
+#wsmgr-row-focused {
+  background-color: alpha(@theme_selected_bg_color, 0.4);
+}
+
+#wsmgr-row-visible {
+  background-color: alpha(@theme_selected_bg_color, 0.15);
+}
+
+#wsmgr-row-urgent {
+  color: #ffffff;
+  background-color: #900000;
+}
+
+#wsmgr-row-loaded {
+  color: alpha(@theme_fg_color, 0.5);
+}
+
+#wsmgr-row-output {
+  color: alpha(@theme_fg_color, 0.6);
   font-weight: bold;
-  font-style: italic;
+}
+
+#loader,
+#config-buttons {
+  margin-top: 0.5rem;
+}
+`
+
+// themes are the built-in themes, loaded on top of defaultCSS. The system
+// theme uses the user’s GTK theme unmodified.
+var themes = map[string]string{
+	"system": ``,
+
+	"light": `
+#win,
+#configeditor,
+treeview.view {
+  color: #222222;
+  background-color: #fafafa;
+}
+
+treeview.view:selected {
   color: #ffffff;
-  background: #000000;
+  background-color: #3465a4;
 }
 
-#grid {
-  margin: 1rem;
-  border: 1px solid grey;
-  padding-left: 1rem;
-  padding-right: 1rem;
+#wsmgr-row-focused {
+  background-color: #c5d7ee;
 }
 
-#icon {
-  margin-top: 1rem;
-  /*background-color: yellow;*/
+#wsmgr-row-visible {
+  background-color: #e6eef8;
 }
 
-#title {
-  padding: 1rem;
-  font-size: 150%;
+#wsmgr-row-loaded {
+  color: #888888;
 }
 
-#message {
-  padding: 1rem;
+#wsmgr-row-output {
+  color: #555555;
 }
+`,
 
-#btnunlock {
-  margin: 1rem;
-  padding: 1rem;
-  border: 1px solid green;
+	"dark": `
+#win,
+#configeditor,
+treeview.view {
+  color: #eeeeee;
+  background-color: #1e1e1e;
 }
 
-#btnunlock:hover {
-  background-color: #555;
+treeview.view:selected {
+  color: #ffffff;
+  background-color: #285577;
 }
 
-#btnunlock:active {
-  background-color: blue;
+#wsmgr-row-focused {
+  background-color: #285577;
+}
+
+#wsmgr-row-visible {
+  background-color: #2f3d4a;
+}
+
+#wsmgr-row-loaded {
+  color: #777777;
+}
+
+#wsmgr-row-output {
+  color: #aaaaaa;
+}
+`,
 }
-`
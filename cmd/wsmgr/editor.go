@@ -0,0 +1,542 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Responses of the configuration editor dialog, in addition to the predefined
+// gtk.ResponseType values.
+const (
+	responseDelete gtk.ResponseType = iota + 1
+	responseDuplicate
+)
+
+// Columns of the executables ListStore in the configuration editor.
+const (
+	execColumnExecutable = iota
+	execColumnName
+	execColumnSource // file to add (empty for existing files)
+	execColumnCopy   // whether to copy (instead of symlink) the source
+	execColumnToggle // whether the executable bits can be changed (no symlink)
+)
+
+// showError displays err in a modal message dialog.
+func showError(parent gtk.IWindow, err error) {
+	log.Print(err)
+	md := gtk.MessageDialogNew(parent, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_OK, "%v", err)
+	md.Run()
+	md.Destroy()
+}
+
+// confirm displays a yes/no question and returns whether yes was chosen.
+func confirm(parent gtk.IWindow, format string, args ...interface{}) bool {
+	md := gtk.MessageDialogNew(parent, gtk.DIALOG_MODAL, gtk.MESSAGE_QUESTION, gtk.BUTTONS_YES_NO, format, args...)
+	defer md.Destroy()
+	return md.Run() == gtk.RESPONSE_YES
+}
+
+// configEditor is the dialog for editing one workspace configuration.
+type configEditor struct {
+	parent *gtk.Window
+	name   string // empty when creating a new configuration
+	create bool   // whether name does not have a configuration directory yet
+
+	dialog   *gtk.Dialog
+	nameE    *gtk.Entry
+	cwdFC    *gtk.FileChooserButton
+	cwdClear bool
+	folderCB *gtk.ComboBoxText
+	pinnedCB *gtk.CheckButton
+	execs    *gtk.ListStore
+	execTV   *gtk.TreeView
+	removed  []string // executables to remove
+
+	origCwd    string
+	origFolder string
+	origPinned bool
+}
+
+// editConfig opens the configuration editor for workspace name. If no
+// configuration directory exists for name (or name is empty), saving creates
+// one.
+func (w *wsmgr) editConfig(name string) {
+	e := &configEditor{parent: w.win, name: name}
+	if name == "" {
+		e.create = true
+	} else if dir, err := configPath(name); err != nil {
+		showError(w.win, err)
+		return
+	} else if _, err := os.Stat(dir); os.IsNotExist(err) {
+		e.create = true
+	}
+	if err := e.init(); err != nil {
+		showError(w.win, err)
+		return
+	}
+	defer e.dialog.Destroy()
+	for {
+		switch e.dialog.Run() {
+		case gtk.RESPONSE_OK:
+			if err := e.save(); err != nil {
+				showError(e.dialog, err)
+				continue
+			}
+
+		case responseDuplicate:
+			dst, err := unusedConfigName(e.name + "-copy")
+			if err == nil {
+				err = duplicateConfig(e.name, dst)
+			}
+			if err != nil {
+				showError(e.dialog, err)
+				continue
+			}
+			log.Printf("duplicated workspace configuration %q to %q", e.name, dst)
+
+		case responseDelete:
+			if !confirm(e.dialog, "Delete the configuration of workspace %q?", e.name) {
+				continue
+			}
+			if err := deleteConfig(e.name); err != nil {
+				showError(e.dialog, err)
+				continue
+			}
+			log.Printf("deleted workspace configuration %q", e.name)
+		}
+		break
+	}
+	w.updateConfiguredWorkspaces()
+}
+
+// unusedConfigName returns name, or name followed by a number, such that no
+// configuration directory exists for it.
+func unusedConfigName(name string) (string, error) {
+	candidate := name
+	for i := 2; ; i++ {
+		dir, err := configPath(candidate)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Lstat(dir); os.IsNotExist(err) {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+}
+
+func (e *configEditor) init() error {
+	d, err := gtk.DialogNew()
+	if err != nil {
+		return err
+	}
+	e.dialog = d
+	d.SetName("configeditor")
+	d.SetTransientFor(e.parent)
+	d.SetModal(true)
+	d.SetDefaultSize(600, 400)
+	if e.create {
+		d.SetTitle("new workspace configuration")
+	} else {
+		d.SetTitle(fmt.Sprintf("workspace configuration %q", e.name))
+		if _, err := d.AddButton("_delete", responseDelete); err != nil {
+			return err
+		}
+		if _, err := d.AddButton("d_uplicate", responseDuplicate); err != nil {
+			return err
+		}
+	}
+	if _, err := d.AddButton("_cancel", gtk.RESPONSE_CANCEL); err != nil {
+		return err
+	}
+	if _, err := d.AddButton("_save", gtk.RESPONSE_OK); err != nil {
+		return err
+	}
+	d.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	grid, err := gtk.GridNew()
+	if err != nil {
+		return err
+	}
+	grid.SetRowSpacing(5)
+	grid.SetColumnSpacing(10)
+	row := 0
+	addRow := func(title string, widgets ...gtk.IWidget) error {
+		label, err := gtk.LabelNew(title)
+		if err != nil {
+			return err
+		}
+		label.SetHAlign(gtk.ALIGN_START)
+		grid.Attach(label, 0, row, 1, 1)
+		for idx, w := range widgets {
+			grid.Attach(w, 1+idx, row, 1, 1)
+		}
+		row++
+		return nil
+	}
+
+	// name
+	e.nameE, err = gtk.EntryNew()
+	if err != nil {
+		return err
+	}
+	e.nameE.SetText(e.name)
+	e.nameE.SetHExpand(true)
+	e.nameE.SetActivatesDefault(true)
+	if err := addRow("name", e.nameE); err != nil {
+		return err
+	}
+
+	// working directory
+	e.cwdFC, err = gtk.FileChooserButtonNew("working directory", gtk.FILE_CHOOSER_ACTION_SELECT_FOLDER)
+	if err != nil {
+		return err
+	}
+	if !e.create {
+		e.origCwd, err = configuredCwd(e.name)
+		if err != nil {
+			log.Print(err) // e.g. dangling symlink, which can be fixed here
+		}
+	}
+	if e.origCwd != "" {
+		e.cwdFC.SetFilename(e.origCwd)
+	}
+	e.cwdFC.Connect("file-set", func() { e.cwdClear = false })
+	clearCwd, err := gtk.ButtonNewWithMnemonic("c_lear")
+	if err != nil {
+		return err
+	}
+	clearCwd.Connect("clicked", func() {
+		e.cwdClear = true
+		e.cwdFC.UnselectAll()
+	})
+	if err := addRow("working directory", e.cwdFC, clearCwd); err != nil {
+		return err
+	}
+
+	// bookmark folder
+	e.folderCB, err = gtk.ComboBoxTextNewWithEntry()
+	if err != nil {
+		return err
+	}
+	if !e.create {
+		e.origFolder, err = configuredBookmarkFolder(e.name)
+		if err != nil {
+			return err
+		}
+	}
+	folders, err := bookmarkFolders()
+	if err != nil {
+		log.Print(err) // the folder can still be entered manually
+	}
+	active := -1
+	for idx, folder := range folders {
+		e.folderCB.AppendText(folder)
+		if folder == e.origFolder {
+			active = idx
+		}
+	}
+	if e.origFolder != "" && active == -1 {
+		e.folderCB.AppendText(e.origFolder)
+		active = len(folders)
+	}
+	e.folderCB.SetActive(active)
+	if err := addRow("bookmark folder", e.folderCB); err != nil {
+		return err
+	}
+
+	// pinned
+	e.pinnedCB, err = gtk.CheckButtonNewWithMnemonic("_keep the workspace number when reordering")
+	if err != nil {
+		return err
+	}
+	if !e.create {
+		e.origPinned, err = configuredPinned(e.name)
+		if err != nil {
+			return err
+		}
+	}
+	e.pinnedCB.SetActive(e.origPinned)
+	if err := addRow("pinned", e.pinnedCB); err != nil {
+		return err
+	}
+
+	// executables
+	if err := e.initExecutables(); err != nil {
+		return err
+	}
+	sw, err := gtk.ScrolledWindowNew(nil, nil)
+	if err != nil {
+		return err
+	}
+	sw.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	sw.SetVExpand(true)
+	sw.Add(e.execTV)
+	if err := addRow("executables", sw); err != nil {
+		return err
+	}
+	buttons, err := e.executableButtons()
+	if err != nil {
+		return err
+	}
+	grid.Attach(buttons, 1, row, 2, 1)
+
+	content, err := d.GetContentArea()
+	if err != nil {
+		return err
+	}
+	content.PackStart(grid, true, true, 10)
+	d.ShowAll()
+	return nil
+}
+
+func (e *configEditor) initExecutables() error {
+	store, err := gtk.ListStoreNew(
+		glib.TYPE_BOOLEAN, // execColumnExecutable
+		glib.TYPE_STRING,  // execColumnName
+		glib.TYPE_STRING,  // execColumnSource
+		glib.TYPE_BOOLEAN, // execColumnCopy
+		glib.TYPE_BOOLEAN) // execColumnToggle
+	if err != nil {
+		return err
+	}
+	e.execs = store
+	if !e.create {
+		entries, err := configuredExecutables(e.name)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			store.Set(store.Append(), []int{
+				execColumnExecutable,
+				execColumnName,
+				execColumnSource,
+				execColumnCopy,
+				execColumnToggle,
+			}, []interface{}{entry.Executable, entry.Name, "", false, !entry.Symlink})
+		}
+	}
+
+	tv, err := gtk.TreeViewNew()
+	if err != nil {
+		return err
+	}
+	{
+		tvc, err := gtk.TreeViewColumnNew()
+		if err != nil {
+			return err
+		}
+		tvc.SetTitle("executable")
+		renderer, err := gtk.CellRendererToggleNew()
+		if err != nil {
+			return err
+		}
+		renderer.Connect("toggled", func(r *gtk.CellRendererToggle, path string) {
+			iter, err := store.GetIterFromString(path)
+			if err != nil {
+				log.Fatalf("BUG: GetIterFromString(%q) = %v", path, err)
+			}
+			if !e.getBool(iter, execColumnToggle) {
+				return // symlink, see setExecutable
+			}
+			executable := e.getBool(iter, execColumnExecutable)
+			store.SetValue(iter, execColumnExecutable, !executable)
+		})
+		tvc.PackStart(renderer, false)
+		tvc.AddAttribute(renderer, "active", execColumnExecutable)
+		tvc.AddAttribute(renderer, "activatable", execColumnToggle)
+		tv.AppendColumn(tvc)
+	}
+	{
+		tvc, err := gtk.TreeViewColumnNew()
+		if err != nil {
+			return err
+		}
+		tvc.SetTitle("name")
+		renderer, err := gtk.CellRendererTextNew()
+		if err != nil {
+			return err
+		}
+		tvc.PackStart(renderer, true)
+		tvc.AddAttribute(renderer, "text", execColumnName)
+		tv.AppendColumn(tvc)
+	}
+	tv.SetModel(store)
+	e.execTV = tv
+	return nil
+}
+
+func (e *configEditor) getString(iter *gtk.TreeIter, column int) string {
+	val, err := e.execs.GetValue(iter, column)
+	if err != nil {
+		log.Fatalf("BUG: GetValue(%d) = %v", column, err)
+	}
+	s, err := val.GetString()
+	if err != nil {
+		log.Fatalf("BUG: GetString() = %v", err)
+	}
+	return s
+}
+
+func (e *configEditor) getBool(iter *gtk.TreeIter, column int) bool {
+	val, err := e.execs.GetValue(iter, column)
+	if err != nil {
+		log.Fatalf("BUG: GetValue(%d) = %v", column, err)
+	}
+	b, err := val.GoValue()
+	if err != nil {
+		log.Fatalf("BUG: GoValue() = %v", err)
+	}
+	return b.(bool)
+}
+
+// executableButtons returns the buttons for adding and removing executables.
+func (e *configEditor) executableButtons() (*gtk.Box, error) {
+	hbox, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	if err != nil {
+		return nil, err
+	}
+	add, err := gtk.ButtonNewWithMnemonic("_add…")
+	if err != nil {
+		return nil, err
+	}
+	copyCB, err := gtk.CheckButtonNewWithMnemonic("c_opy instead of symlink")
+	if err != nil {
+		return nil, err
+	}
+	remove, err := gtk.ButtonNewWithMnemonic("_remove")
+	if err != nil {
+		return nil, err
+	}
+	add.Connect("clicked", func() {
+		fc, err := gtk.FileChooserDialogNewWith2Buttons(
+			"add executable",
+			e.dialog,
+			gtk.FILE_CHOOSER_ACTION_OPEN,
+			"_cancel", gtk.RESPONSE_CANCEL,
+			"_add", gtk.RESPONSE_ACCEPT)
+		if err != nil {
+			showError(e.dialog, err)
+			return
+		}
+		defer fc.Destroy()
+		if fc.Run() != gtk.RESPONSE_ACCEPT {
+			return
+		}
+		src := fc.GetFilename()
+		fi, err := os.Stat(src)
+		if err != nil {
+			showError(e.dialog, err)
+			return
+		}
+		for iter, ok := e.execs.GetIterFirst(); ok; ok = e.execs.IterNext(iter) {
+			if e.getString(iter, execColumnName) == fi.Name() {
+				showError(e.dialog, fmt.Errorf("%s: the workspace already contains a file of this name", fi.Name()))
+				return
+			}
+		}
+		e.execs.Set(e.execs.Append(), []int{
+			execColumnExecutable,
+			execColumnName,
+			execColumnSource,
+			execColumnCopy,
+			execColumnToggle,
+		}, []interface{}{
+			fi.Mode()&0100 != 0,
+			fi.Name(),
+			src,
+			copyCB.GetActive(),
+			copyCB.GetActive(), // copies can be changed, symlinks cannot
+		})
+	})
+	remove.Connect("clicked", func() {
+		sel, err := e.execTV.GetSelection()
+		if err != nil {
+			log.Fatal(err)
+		}
+		_, iter, ok := sel.GetSelected()
+		if !ok {
+			return
+		}
+		if e.getString(iter, execColumnSource) == "" {
+			e.removed = append(e.removed, e.getString(iter, execColumnName))
+		}
+		e.execs.Remove(iter)
+	})
+	hbox.PackStart(add, false, false, 0)
+	hbox.PackStart(copyCB, false, false, 0)
+	hbox.PackEnd(remove, false, false, 0)
+	return hbox, nil
+}
+
+// save applies the changes made in the dialog.
+func (e *configEditor) save() error {
+	name, err := e.nameE.GetText()
+	if err != nil {
+		return err
+	}
+	if e.create {
+		if err := createConfig(name); err != nil {
+			return err
+		}
+		log.Printf("created workspace configuration %q", name)
+	} else if name != e.name {
+		if err := renameConfig(e.name, name); err != nil {
+			return err
+		}
+		log.Printf("renamed workspace configuration %q to %q", e.name, name)
+	}
+	// Subsequent errors must not lead to creating (or renaming) again.
+	e.name, e.create = name, false
+
+	if newCwd := e.cwdFC.GetFilename(); e.cwdClear || (newCwd != "" && newCwd != e.origCwd) {
+		if e.cwdClear {
+			newCwd = ""
+		}
+		if err := setConfigCwd(name, newCwd); err != nil {
+			return err
+		}
+		e.origCwd, e.cwdClear = newCwd, false
+	}
+
+	if folder := e.folderCB.GetActiveText(); folder != e.origFolder {
+		if err := setBookmarkFolder(name, folder); err != nil {
+			return err
+		}
+		e.origFolder = folder
+	}
+
+	if pinned := e.pinnedCB.GetActive(); pinned != e.origPinned {
+		if err := setPinned(name, pinned); err != nil {
+			return err
+		}
+		e.origPinned = pinned
+	}
+
+	for _, file := range e.removed {
+		if err := removeExecutable(name, file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	e.removed = nil
+	for iter, ok := e.execs.GetIterFirst(); ok; ok = e.execs.IterNext(iter) {
+		file := e.getString(iter, execColumnName)
+		if src := e.getString(iter, execColumnSource); src != "" {
+			if err := addExecutable(name, src, e.getBool(iter, execColumnCopy)); err != nil {
+				return err
+			}
+			e.execs.SetValue(iter, execColumnSource, "") // added
+		}
+		if err := setExecutable(name, file, e.getBool(iter, execColumnExecutable)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stapelberg/wsmgr-for-i3/internal/wsname"
+)
+
+// setenv sets the environment variable key to value for the duration of the
+// test (t.Setenv requires Go 1.17).
+func setenv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// withConfigDir points os.UserConfigDir (and os.UserCacheDir) to a temporary
+// directory for the duration of the test and returns the wsmgr-for-i3
+// directory within it.
+func withConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	setenv(t, "XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	setenv(t, "XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	configDir := filepath.Join(dir, "config", "wsmgr-for-i3")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return configDir
+}
+
+// withNameFormat sets nameFormat to the default (resolving ambiguous names via
+// the configured workspaces, like loadNameFormat) for the duration of the test.
+func withNameFormat(t *testing.T) {
+	t.Helper()
+	old := nameFormat
+	t.Cleanup(func() { nameFormat = old })
+	f, err := wsname.New(wsname.DefaultTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nameFormat = f.WithIcons(lookupWorkspace)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/stapelberg/wsmgr-for-i3/internal/wm"
+)
+
+// i3ConfigCache holds the i3 config, which is only read once: reloading it
+// while wsmgr is running is rare, and adding or re-ordering workspaces should
+// not wait for it.
+var i3ConfigCache struct {
+	mu     sync.Mutex
+	config string
+	read   bool
+}
+
+// i3Config returns the i3 config, including the files it includes (see
+// wm.Config).
+func i3Config() (string, error) {
+	i3ConfigCache.mu.Lock()
+	defer i3ConfigCache.mu.Unlock()
+	if !i3ConfigCache.read {
+		config, err := wm.Config()
+		if err != nil {
+			return "", err
+		}
+		i3ConfigCache.config = config
+		i3ConfigCache.read = true
+	}
+	return i3ConfigCache.config, nil
+}
+
+// pangoMarkup returns whether the bar renders Pango markup in workspace names:
+// i3bar does when using a pango: font, swaybar only with pango_markup enabled
+// in its bar block.
+func pangoMarkup(config string) bool {
+	for _, line := range strings.Split(config, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if wm.Sway() {
+			if fields[0] == "pango_markup" && fields[1] == "enabled" {
+				return true
+			}
+			continue
+		}
+		if fields[0] == "font" && strings.HasPrefix(fields[1], "pango:") {
+			return true
+		}
+	}
+	return false
+}
+
+// colorMarkup returns whether workspace colors (see readColor) can be added to
+// workspace names as Pango markup. Without markup support, the bar would
+// display the markup literally.
+func colorMarkup() bool {
+	config, err := i3Config()
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	return pangoMarkup(config)
+}
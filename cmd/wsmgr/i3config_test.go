@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPangoMarkup(t *testing.T) {
+	for _, tt := range []struct {
+		desc   string
+		sway   bool
+		config string
+		want   bool
+	}{
+		{"i3, pango font", false, "font pango:DejaVu Sans Mono 10\n", true},
+		{"i3, bar pango font", false, "bar {\n\tfont pango:DejaVu Sans Mono 10\n}\n", true},
+		{"i3, X core font", false, "font -misc-fixed-medium-r-normal--13-120-75-75-C-70-iso10646-1\n", false},
+		{"i3, no font", false, "", false},
+		{"sway, markup enabled", true, "font DejaVu Sans Mono 10\nbar {\n\tpango_markup enabled\n}\n", true},
+		{"sway, pango font", true, "font pango:DejaVu Sans Mono 10\n", false},
+		{"sway, markup disabled", true, "bar {\n\tpango_markup disabled\n}\n", false},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if tt.sway {
+				setenv(t, "SWAYSOCK", "/nonexistent")
+			} else {
+				setenv(t, "SWAYSOCK", "")
+			}
+			if got := pangoMarkup(tt.config); got != tt.want {
+				t.Errorf("pangoMarkup(%q) = %v, want %v", tt.config, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// The whole program uses log.Fatal for error handling, under the assumption
+// that any error is either a bug (likely with how we use GTK, or in gotk3), or
+// i3 having gone away, in which case this program should terminate, too.
+//
+// Workspaces are configured in ~/.config/wsmgr-for-i3/<name>. Each setting is
+// configured via its own file.
+package main
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/stapelberg/wsmgr-for-i3/internal/wm"
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "wsmgr",
+	Short: "workspace manager",
+	Long:  "workspace manager",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		nameFormat, err = loadNameFormat()
+		return err
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return gui()
+	},
+}
+
+var autosaveCmd = &cobra.Command{
+	Use:   "autosave",
+	Short: "save workspace names to the autosave file",
+	Long:  "do not show the GUI, instead save workspace names to the autosave file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return autosave()
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "restore workspace names from the autosave file",
+	Long:  "do not show the GUI, instead restore workspace names from the autosave file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return restore(dryRun)
+	},
+}
+
+var (
+	dryRun      bool
+	theme       string
+	focusSearch bool
+	selectName  string
+)
+
+func ws() error {
+	rootCmd.Flags().BoolVarP(&focusSearch, "focus-search", "", false, "focus the search of the configured workspaces")
+	rootCmd.Flags().StringVarP(&selectName, "select", "", "", "select the open or configured workspace of this name")
+	rootCmd.Flags().StringVarP(&theme, "theme", "", "system", "built-in theme (system, light or dark), see also ~/.config/wsmgr-for-i3/style.css")
+	restoreCmd.Flags().BoolVarP(&dryRun, "dry-run", "", false, "do not change anything (dry-run mode)")
+	rootCmd.AddCommand(autosaveCmd)
+	rootCmd.AddCommand(restoreCmd)
+	menuCmd.Flags().BoolVarP(&dmenu, "dmenu", "", false, "pipe the menu through --dmenu-command instead of implementing rofi’s script mode")
+	menuCmd.Flags().StringVarP(&dmenuCommand, "dmenu-command", "", "dmenu -i -p workspace", "dmenu-compatible command (e.g. rofi -dmenu)")
+	rootCmd.AddCommand(menuCmd)
+	tuiCmd.Flags().BoolVarP(&tuiQuit, "quit", "", false, "exit after switching to or loading a workspace")
+	rootCmd.AddCommand(tuiCmd)
+
+	if err := rootCmd.Execute(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func main() {
+	wm.Init()
+	if err := ws(); err != nil {
+		log.Fatal(err)
+	}
+}
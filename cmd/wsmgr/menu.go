@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/stapelberg/wsmgr-for-i3/internal/wsname"
+	"go.i3wm.org/i3/v4"
+)
+
+// Prefixes of menu entries and input which select an action other than
+// switching to a workspace.
+const (
+	menuLoadPrefix   = "load:"
+	menuRenamePrefix = "rename:"
+	menuMovePrefix   = "move:"
+)
+
+// menuEntries returns the open workspaces (their names start with their
+// number, without color markup), followed by the configured workspaces which are not open.
+func menuEntries() ([]string, error) {
+	workspaces, err := i3.GetWorkspaces()
+	if err != nil {
+		return nil, err
+	}
+	names, err := configuredWorkspaces()
+	if err != nil {
+		return nil, err
+	}
+	open := make(map[string]bool)
+	var entries []string
+	for _, ws := range workspaces {
+		open[nameWithoutNumberPrefix(ws)] = true
+		entries = append(entries, wsname.StripMarkup(ws.Name))
+	}
+	for _, name := range names {
+		if open[name] {
+			continue
+		}
+		entries = append(entries, menuLoadPrefix+" "+name)
+	}
+	return entries, nil
+}
+
+// findWorkspace returns the open workspace called name (with or without number
+// prefix), or with number name.
+func findWorkspace(workspaces []i3.Workspace, name string) (i3.Workspace, bool) {
+	for _, ws := range workspaces {
+		if wsname.StripMarkup(ws.Name) == name || nameWithoutNumberPrefix(ws) == name {
+			return ws, true
+		}
+	}
+	if num, err := strconv.ParseInt(name, 10, 64); err == nil {
+		for _, ws := range workspaces {
+			if ws.Num == num {
+				return ws, true
+			}
+		}
+	}
+	return i3.Workspace{}, false
+}
+
+// menuSelect performs the action for a selected menu entry (or custom input):
+//
+//	rename: <name>   renames the focused workspace, keeping its number
+//	move: <name>     moves the focused window to the workspace
+//	load: <name>     loads the configured workspace (or switches to it, if open)
+//	<name>           switches to the open workspace, or loads the workspace
+//
+// Like in the GUI, loaded (or new) workspaces are numbered according to the
+// numbering policy (see nextWorkspaceName). It returns whether a workspace was
+// loaded, in which case the process needs to wait for its windows to be placed.
+func menuSelect(selection string) (loaded bool, _ error) {
+	selection = strings.TrimSpace(selection)
+	if selection == "" {
+		return false, nil
+	}
+	workspaces, err := i3.GetWorkspaces()
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case strings.HasPrefix(selection, menuRenamePrefix):
+		newName := strings.TrimSpace(strings.TrimPrefix(selection, menuRenamePrefix))
+		for _, ws := range workspaces {
+			if !ws.Focused {
+				continue
+			}
+			newName = keepNumberPrefix(ws, newName)
+			cmd := fmt.Sprintf(`rename workspace "%s" to "%s"`, ws.Name, newName)
+			log.Printf("renaming workspace: %q", cmd)
+			_, err := i3.RunCommand(cmd)
+			return false, err
+		}
+		return false, fmt.Errorf("no focused workspace found")
+
+	case strings.HasPrefix(selection, menuMovePrefix):
+		target := strings.TrimSpace(strings.TrimPrefix(selection, menuMovePrefix))
+		name := nextWorkspaceName(workspaces, target)
+		if ws, ok := findWorkspace(workspaces, target); ok {
+			name = ws.Name
+		}
+		_, err := i3.RunCommand(fmt.Sprintf(`move container to workspace "%s"`, name))
+		return false, err
+	}
+
+	name := strings.TrimSpace(strings.TrimPrefix(selection, menuLoadPrefix))
+	// Loading an open workspace (e.g. typed while the menu still listed it as
+	// configured) switches to it instead of opening it a second time.
+	if ws, ok := findWorkspace(workspaces, name); ok {
+		_, err := i3.RunCommand(fmt.Sprintf(`workspace "%s"`, ws.Name))
+		return false, err
+	}
+	// Unlike the GUI, the menu does not move a window to the new workspace:
+	// the focused window is not ours.
+	newName := nextWorkspaceName(workspaces, name)
+	if _, err := i3.RunCommand(fmt.Sprintf(`workspace "%s"`, newName)); err != nil {
+		return false, err
+	}
+	configured, err := configuredWorkspaces()
+	if err != nil {
+		return false, err
+	}
+	for _, c := range configured {
+		if c == name {
+			return true, loadWorkspace(name)
+		}
+	}
+	return false, nil // new, unconfigured workspace
+}
+
+// rofiMenu implements rofi’s script mode: rofi runs wsmgr menu without
+// arguments to get the entries, and with the selected entry (or custom input)
+// as argument once the user made a selection.
+func rofiMenu(args []string) (loaded bool, _ error) {
+	if len(args) == 0 {
+		entries, err := menuEntries()
+		if err != nil {
+			return false, err
+		}
+		fmt.Printf("\x00prompt\x1fworkspace\n")
+		for _, entry := range entries {
+			fmt.Println(entry)
+		}
+		return false, nil
+	}
+	// Printing nothing closes rofi. Close stdout right away, so that
+	// neither this process nor the programs started when loading a workspace
+	// keep rofi open.
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	if err := stdout.Close(); err != nil {
+		return false, err
+	}
+	return menuSelect(strings.Join(args, " "))
+}
+
+// dmenuMenu pipes the entries through the dmenu-compatible command and
+// performs the action for its output.
+func dmenuMenu(command string) (loaded bool, _ error) {
+	entries, err := menuEntries()
+	if err != nil {
+		return false, err
+	}
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return false, fmt.Errorf("empty --dmenu-command")
+	}
+	cmd := exec.Command(fields[0], fields[1:]...)
+	cmd.Stdin = strings.NewReader(strings.Join(entries, "\n") + "\n")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return false, nil // dmenu convention: selection aborted
+		}
+		return false, fmt.Errorf("%v: %v", cmd.Args, err)
+	}
+	return menuSelect(string(bytes.TrimSpace(out)))
+}
+
+var menuCmd = &cobra.Command{
+	Use:   "menu [selection]",
+	Short: "rofi script mode (or dmenu) menu for workspaces",
+	Long: `do not show the GUI, instead list open and configured workspaces for rofi’s script mode:
+
+  rofi -show wsmgr -modi "wsmgr:wsmgr menu"
+
+or pipe them through a dmenu-compatible command (see --dmenu).
+
+Selecting an open workspace switches to it, selecting a configured workspace
+loads it. “rename: <name>” renames the focused workspace, “move: <name>” moves
+the focused window to the workspace.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var loaded bool
+		var err error
+		if dmenu {
+			loaded, err = dmenuMenu(dmenuCommand)
+		} else {
+			loaded, err = rofiMenu(args)
+		}
+		if err != nil {
+			return err
+		}
+		if loaded {
+			// Keep running until all windows of the loaded workspace
+			// were placed.
+			trackers.Wait()
+		}
+		return nil
+	},
+}
+
+var (
+	dmenu        bool
+	dmenuCommand string
+)
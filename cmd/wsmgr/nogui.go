@@ -0,0 +1,11 @@
+//go:build !cgo
+// +build !cgo
+
+package main
+
+import "fmt"
+
+// gui is not available without cgo, which gotk3 requires.
+func gui() error {
+	return fmt.Errorf("wsmgr was built without cgo, so the GTK window is not available (use wsmgr tui or wsmgr menu)")
+}
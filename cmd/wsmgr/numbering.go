@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.i3wm.org/i3/v4"
+)
+
+// numberingPolicy determines which numbers workspaces get when adding, loading
+// and re-ordering them.
+type numberingPolicy string
+
+const (
+	// policyCompact numbers new workspaces after the highest numbered
+	// workspace, and re-ordering numbers the workspaces 1, 2, …, N.
+	policyCompact numberingPolicy = "compact"
+
+	// policyFirstGap numbers new workspaces with the lowest unused number, and
+	// re-ordering keeps the numbers (including the gaps between them).
+	policyFirstGap numberingPolicy = "first-gap"
+
+	// policyOutputs numbers workspaces within the number range of their
+	// output, e.g. 1-9 on the laptop screen and 11-19 on the external monitor.
+	policyOutputs numberingPolicy = "outputs"
+)
+
+// numberRange is an inclusive range of workspace numbers.
+type numberRange struct {
+	lo, hi int64
+}
+
+func (r numberRange) contains(num int64) bool {
+	return r.lo <= num && num <= r.hi
+}
+
+// numbering is the configured numbering policy, read from
+// ~/.config/wsmgr-for-i3/numbering, e.g.:
+//
+//	policy outputs
+//	output eDP-1 1-9
+//	output HDMI-1 11-19
+//
+// Without output lines, the ranges of policyOutputs are derived from the
+// workspace … output … assignments in the i3 config (including the files it
+// includes, see wm.Config).
+type numbering struct {
+	policy numberingPolicy
+	ranges map[string]numberRange // by output name
+}
+
+func readNumbering() (*numbering, error) {
+	n := &numbering{
+		policy: policyCompact,
+		ranges: make(map[string]numberRange),
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	fn := filepath.Join(configDir, "wsmgr-for-i3", "numbering")
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return n, nil
+		}
+		return nil, err
+	}
+	for idx, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch {
+		case fields[0] == "policy" && len(fields) == 2:
+			switch policy := numberingPolicy(fields[1]); policy {
+			case policyCompact, policyFirstGap, policyOutputs:
+				n.policy = policy
+			default:
+				return nil, fmt.Errorf("%s:%d: unknown policy %q", fn, idx+1, fields[1])
+			}
+
+		case fields[0] == "output" && len(fields) == 3:
+			r, err := parseNumberRange(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", fn, idx+1, err)
+			}
+			n.ranges[fields[1]] = r
+
+		default:
+			return nil, fmt.Errorf("%s:%d: syntax error: %q", fn, idx+1, line)
+		}
+	}
+	if n.policy == policyOutputs && len(n.ranges) == 0 {
+		cfg, err := i3Config()
+		if err != nil {
+			return nil, err
+		}
+		n.ranges = outputRanges(cfg)
+	}
+	return n, nil
+}
+
+// parseNumberRange parses a range like 11-19 (or a single number).
+func parseNumberRange(s string) (numberRange, error) {
+	lo, hi := s, s
+	if idx := strings.IndexByte(s, '-'); idx > -1 {
+		lo, hi = s[:idx], s[idx+1:]
+	}
+	var r numberRange
+	var err1, err2 error
+	r.lo, err1 = strconv.ParseInt(lo, 10, 64)
+	r.hi, err2 = strconv.ParseInt(hi, 10, 64)
+	if err1 != nil || err2 != nil || r.lo < 1 || r.hi < r.lo {
+		return numberRange{}, fmt.Errorf("invalid number range %q", s)
+	}
+	return r, nil
+}
+
+// outputRanges derives the number range of each output from the
+// workspace <ws> output <output> lines in the i3 config: the range spans from
+// the lowest to the highest workspace number assigned to the output.
+func outputRanges(config string) map[string]numberRange {
+	vars := make(map[string]string)
+	expand := func(s string) string {
+		if v, ok := vars[s]; ok {
+			s = v
+		}
+		return strings.Trim(s, `"`)
+	}
+	ranges := make(map[string]numberRange)
+	for _, line := range strings.Split(config, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == "set" && strings.HasPrefix(fields[1], "$") {
+			vars[fields[1]] = strings.Join(fields[2:], " ")
+			continue
+		}
+		if len(fields) < 4 || fields[0] != "workspace" || fields[2] != "output" {
+			continue
+		}
+		// Only the leading number matters, e.g. 1 in "1: www". Quoted names
+		// containing spaces are not supported.
+		ws := expand(fields[1])
+		end := 0
+		for end < len(ws) && ws[end] >= '0' && ws[end] <= '9' {
+			end++
+		}
+		num, err := strconv.ParseInt(ws[:end], 10, 64)
+		if err != nil || num < 1 {
+			continue // named workspace
+		}
+		// Further outputs are fallbacks, the first one is preferred.
+		output := expand(fields[3])
+		r, ok := ranges[output]
+		if !ok {
+			r = numberRange{lo: num, hi: num}
+		}
+		if num < r.lo {
+			r.lo = num
+		}
+		if num > r.hi {
+			r.hi = num
+		}
+		ranges[output] = r
+	}
+	return ranges
+}
+
+// numberingOrDefault returns the configured numbering policy, falling back to
+// policyCompact if the configuration cannot be read.
+func numberingOrDefault() *numbering {
+	n, err := readNumbering()
+	if err != nil {
+		log.Print(err)
+		return &numbering{policy: policyCompact}
+	}
+	return n
+}
+
+// first returns the lowest number for workspaces on output.
+func (n *numbering) first(output string) int64 {
+	if r, ok := n.ranges[output]; ok && n.policy == policyOutputs {
+		return r.lo
+	}
+	return 1
+}
+
+// allowed returns whether workspaces on output can use num. Once the range of
+// an output is exhausted, its workspaces continue with numbers which are not
+// part of any range.
+func (n *numbering) allowed(output string, num int64) bool {
+	if n.policy != policyOutputs {
+		return true
+	}
+	if r, ok := n.ranges[output]; ok && num <= r.hi {
+		return num >= r.lo
+	}
+	for _, r := range n.ranges {
+		if r.contains(num) {
+			return false
+		}
+	}
+	return true
+}
+
+// free returns the lowest number starting at num which workspaces on output can
+// use and which is not taken.
+func (n *numbering) free(output string, num int64, taken map[int64]bool) int64 {
+	for !n.allowed(output, num) || taken[num] {
+		num++
+	}
+	return num
+}
+
+// next returns the number for a new workspace, which i3 creates on the output
+// of the focused workspace.
+func (n *numbering) next(workspaces []i3.Workspace, pinned map[string]int64) int64 {
+	taken := pinnedNumbers(workspaces, pinned)
+	if n.policy == policyFirstGap || n.policy == policyOutputs {
+		var output string
+		for _, ws := range workspaces {
+			taken[ws.Num] = true
+			if ws.Focused {
+				output = ws.Output
+			}
+		}
+		return n.free(output, n.first(output), taken)
+	}
+
+	var highest int64
+	for _, ws := range workspaces {
+		if _, ok := pinned[nameWithoutNumberPrefix(ws)]; ok {
+			continue
+		}
+		if ws.Num > highest {
+			highest = ws.Num
+		}
+	}
+	return n.free("", highest+1, taken)
+}
+
+// renumber returns the numbers of the workspaces in the specified order. Pinned
+// workspaces (see pinnedWorkspaces) keep their number (or get their configured
+// number), and the other workspaces are numbered around them.
+func (n *numbering) renumber(order []i3.Workspace, pinned map[string]int64) []int64 {
+	taken := pinnedNumbers(order, pinned)
+	nums := make([]int64, len(order))
+	groups := make(map[string][]int) // indexes into order, by output
+	for idx, ws := range order {
+		if num, ok := pinned[nameWithoutNumberPrefix(ws)]; ok {
+			if num == 0 {
+				num = ws.Num
+			}
+			nums[idx] = num
+			continue
+		}
+		var output string
+		if n.policy == policyOutputs {
+			output = ws.Output
+		}
+		groups[output] = append(groups[output], idx)
+	}
+	outputs := make([]string, 0, len(groups))
+	for output := range groups {
+		outputs = append(outputs, output)
+	}
+	sort.Strings(outputs)
+	for _, output := range outputs {
+		idxs := groups[output]
+		var pool []int64
+		if n.policy == policyFirstGap {
+			for _, idx := range idxs {
+				if num := order[idx].Num; num > 0 && !taken[num] {
+					pool = append(pool, num)
+					taken[num] = true
+				}
+			}
+		}
+		for num := n.first(output); len(pool) < len(idxs); num++ {
+			num = n.free(output, num, taken)
+			pool = append(pool, num)
+			taken[num] = true
+		}
+		sort.Slice(pool, func(i, j int) bool { return pool[i] < pool[j] })
+		for i, idx := range idxs {
+			nums[idx] = pool[i]
+		}
+	}
+	return nums
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"go.i3wm.org/i3/v4"
+)
+
+// workspace returns a workspace named like wsmgr names them. Workspaces with a
+// negative num are named workspaces without number.
+func workspace(num int64, name, output string) i3.Workspace {
+	w := i3.Workspace{Num: num, Name: name, Output: output}
+	if num > 0 {
+		w.Name = fmt.Sprintf("%d: %s", num, name)
+	}
+	return w
+}
+
+func focused(w i3.Workspace) i3.Workspace {
+	w.Focused = true
+	return w
+}
+
+var (
+	twoOutputs = map[string]numberRange{
+		"eDP-1":  {lo: 1, hi: 9},
+		"HDMI-1": {lo: 11, hi: 19},
+	}
+	smallLaptop = map[string]numberRange{
+		"eDP-1":  {lo: 1, hi: 2},
+		"HDMI-1": {lo: 11, hi: 19},
+	}
+	adjacent = map[string]numberRange{
+		"eDP-1":  {lo: 1, hi: 2},
+		"HDMI-1": {lo: 3, hi: 9},
+	}
+)
+
+func TestParseNumberRange(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    numberRange
+		wantErr bool
+	}{
+		{in: "11-19", want: numberRange{lo: 11, hi: 19}},
+		{in: "5", want: numberRange{lo: 5, hi: 5}},
+		{in: "3-3", want: numberRange{lo: 3, hi: 3}},
+		{in: "0-3", wantErr: true},
+		{in: "9-1", wantErr: true},
+		{in: "1-", wantErr: true},
+		{in: "-3", wantErr: true},
+		{in: "a-b", wantErr: true},
+		{in: "", wantErr: true},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseNumberRange(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNumberRange(%q) = %v, want error: %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseNumberRange(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputRanges(t *testing.T) {
+	for _, tt := range []struct {
+		desc   string
+		config string
+		want   map[string]numberRange
+	}{
+		{
+			desc: "numbers",
+			config: `workspace 1 output eDP-1
+workspace 9 output eDP-1
+workspace 11 output HDMI-1 DP-1
+workspace 15 output HDMI-1
+workspace 13 output HDMI-1
+`,
+			want: map[string]numberRange{
+				"eDP-1":  {lo: 1, hi: 9},
+				"HDMI-1": {lo: 11, hi: 15},
+			},
+		},
+
+		{
+			desc: "variables",
+			config: `set $laptop eDP-1
+set $ws1 "1: www"
+set $ws4 4
+workspace $ws1 output $laptop
+workspace $ws4 output $laptop
+workspace "7" output "HDMI-1"
+`,
+			want: map[string]numberRange{
+				"eDP-1":  {lo: 1, hi: 4},
+				"HDMI-1": {lo: 7, hi: 7},
+			},
+		},
+
+		{
+			desc: "ignored lines",
+			config: `workspace www output eDP-1
+workspace 0 output eDP-1
+workspace_layout tabbed
+bindsym $mod+1 workspace 1
+workspace 1 output
+`,
+			want: map[string]numberRange{},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := outputRanges(tt.config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("outputRanges = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	withNameFormat(t)
+	for _, tt := range []struct {
+		desc       string
+		policy     numberingPolicy
+		ranges     map[string]numberRange
+		workspaces []i3.Workspace
+		pinned     map[string]int64
+		want       int64
+	}{
+		{
+			desc:   "compact",
+			policy: policyCompact,
+			workspaces: []i3.Workspace{
+				focused(workspace(1, "www", "eDP-1")),
+				workspace(3, "kint", "eDP-1"),
+			},
+			want: 4,
+		},
+
+		{
+			desc:       "compact, no workspaces",
+			policy:     policyCompact,
+			workspaces: []i3.Workspace{focused(workspace(-1, "scratch", "eDP-1"))},
+			want:       1,
+		},
+
+		{
+			desc:   "compact, pinned number",
+			policy: policyCompact,
+			workspaces: []i3.Workspace{
+				focused(workspace(1, "www", "eDP-1")),
+				workspace(3, "kint", "eDP-1"),
+			},
+			pinned: map[string]int64{"mail": 4},
+			want:   5,
+		},
+
+		{
+			desc:   "compact, open pinned workspace",
+			policy: policyCompact,
+			workspaces: []i3.Workspace{
+				focused(workspace(1, "www", "eDP-1")),
+				workspace(3, "kint", "eDP-1"),
+				workspace(9, "mail", "eDP-1"),
+			},
+			pinned: map[string]int64{"mail": 0},
+			want:   4,
+		},
+
+		{
+			desc:   "first-gap",
+			policy: policyFirstGap,
+			workspaces: []i3.Workspace{
+				focused(workspace(1, "www", "eDP-1")),
+				workspace(3, "kint", "eDP-1"),
+			},
+			want: 2,
+		},
+
+		{
+			desc:   "first-gap, pinned number",
+			policy: policyFirstGap,
+			workspaces: []i3.Workspace{
+				focused(workspace(1, "www", "eDP-1")),
+				workspace(3, "kint", "eDP-1"),
+			},
+			pinned: map[string]int64{"mail": 2},
+			want:   4,
+		},
+
+		{
+			desc:   "outputs",
+			policy: policyOutputs,
+			ranges: twoOutputs,
+			workspaces: []i3.Workspace{
+				workspace(1, "www", "eDP-1"),
+				focused(workspace(11, "kint", "HDMI-1")),
+			},
+			want: 12,
+		},
+
+		{
+			desc:   "outputs, pinned number in range",
+			policy: policyOutputs,
+			ranges: twoOutputs,
+			workspaces: []i3.Workspace{
+				workspace(1, "www", "eDP-1"),
+				focused(workspace(11, "kint", "HDMI-1")),
+			},
+			pinned: map[string]int64{"mail": 12},
+			want:   13,
+		},
+
+		{
+			desc:   "outputs, range exhausted",
+			policy: policyOutputs,
+			ranges: smallLaptop,
+			workspaces: []i3.Workspace{
+				focused(workspace(1, "www", "eDP-1")),
+				workspace(2, "kint", "eDP-1"),
+			},
+			want: 3,
+		},
+
+		{
+			desc:   "outputs, range exhausted, skipping other ranges",
+			policy: policyOutputs,
+			ranges: adjacent,
+			workspaces: []i3.Workspace{
+				focused(workspace(1, "www", "eDP-1")),
+				workspace(2, "kint", "eDP-1"),
+			},
+			want: 10,
+		},
+
+		{
+			desc:       "outputs, output without range",
+			policy:     policyOutputs,
+			ranges:     twoOutputs,
+			workspaces: []i3.Workspace{focused(workspace(1, "www", "DP-2"))},
+			want:       10,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			n := &numbering{policy: tt.policy, ranges: tt.ranges}
+			if got := n.next(tt.workspaces, tt.pinned); got != tt.want {
+				t.Errorf("next = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenumber(t *testing.T) {
+	withNameFormat(t)
+	for _, tt := range []struct {
+		desc   string
+		policy numberingPolicy
+		ranges map[string]numberRange
+		order  []i3.Workspace
+		pinned map[string]int64
+		want   []int64
+	}{
+		{
+			desc:   "compact",
+			policy: policyCompact,
+			order: []i3.Workspace{
+				workspace(3, "mail", "eDP-1"),
+				workspace(1, "www", "eDP-1"),
+				workspace(7, "kint", "eDP-1"),
+			},
+			want: []int64{1, 2, 3},
+		},
+
+		{
+			desc:   "compact, open pinned workspace",
+			policy: policyCompact,
+			order: []i3.Workspace{
+				workspace(2, "kint", "eDP-1"),
+				workspace(1, "www", "eDP-1"),
+				workspace(3, "mail", "eDP-1"),
+			},
+			pinned: map[string]int64{"www": 0},
+			want:   []int64{2, 1, 3},
+		},
+
+		{
+			desc:   "compact, pinned number",
+			policy: policyCompact,
+			order: []i3.Workspace{
+				workspace(3, "mail", "eDP-1"),
+				workspace(1, "www", "eDP-1"),
+				workspace(2, "kint", "eDP-1"),
+			},
+			pinned: map[string]int64{"mail": 2},
+			want:   []int64{2, 1, 3},
+		},
+
+		{
+			desc:   "first-gap",
+			policy: policyFirstGap,
+			order: []i3.Workspace{
+				workspace(5, "mail", "eDP-1"),
+				workspace(2, "www", "eDP-1"),
+				workspace(8, "kint", "eDP-1"),
+			},
+			want: []int64{2, 5, 8},
+		},
+
+		{
+			desc:   "first-gap, unnumbered workspace",
+			policy: policyFirstGap,
+			order: []i3.Workspace{
+				workspace(-1, "www", "eDP-1"),
+				workspace(4, "kint", "eDP-1"),
+			},
+			want: []int64{1, 4},
+		},
+
+		{
+			desc:   "first-gap, pinned number in pool",
+			policy: policyFirstGap,
+			order: []i3.Workspace{
+				workspace(3, "mail", "eDP-1"),
+				workspace(5, "www", "eDP-1"),
+			},
+			pinned: map[string]int64{"www": 3},
+			want:   []int64{1, 3},
+		},
+
+		{
+			desc:   "outputs",
+			policy: policyOutputs,
+			ranges: twoOutputs,
+			order: []i3.Workspace{
+				workspace(5, "a", "eDP-1"),
+				workspace(12, "b", "HDMI-1"),
+				workspace(3, "c", "eDP-1"),
+				workspace(20, "d", "HDMI-1"),
+			},
+			want: []int64{1, 11, 2, 12},
+		},
+
+		{
+			desc:   "outputs, pinned number in range",
+			policy: policyOutputs,
+			ranges: twoOutputs,
+			order: []i3.Workspace{
+				workspace(5, "a", "eDP-1"),
+				workspace(12, "b", "HDMI-1"),
+				workspace(3, "c", "eDP-1"),
+				workspace(20, "d", "HDMI-1"),
+			},
+			pinned: map[string]int64{"b": 12},
+			want:   []int64{1, 12, 2, 11},
+		},
+
+		{
+			desc:   "outputs, range exhausted",
+			policy: policyOutputs,
+			ranges: smallLaptop,
+			order: []i3.Workspace{
+				workspace(1, "a", "eDP-1"),
+				workspace(2, "b", "eDP-1"),
+				workspace(11, "c", "HDMI-1"),
+				workspace(4, "d", "eDP-1"),
+			},
+			want: []int64{1, 2, 11, 3},
+		},
+
+		{
+			desc:   "outputs, range exhausted, skipping other ranges",
+			policy: policyOutputs,
+			ranges: adjacent,
+			order: []i3.Workspace{
+				workspace(1, "a", "eDP-1"),
+				workspace(2, "b", "eDP-1"),
+				workspace(4, "c", "eDP-1"),
+			},
+			want: []int64{1, 2, 10},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			n := &numbering{policy: tt.policy, ranges: tt.ranges}
+			if got := n.renumber(tt.order, tt.pinned); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("renumber = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// styleReloadInterval is how often style.css is checked for modifications.
+const styleReloadInterval = 2 * time.Second
+
+// initStyle loads defaultCSS and the specified built-in theme, then the user’s
+// ~/.config/wsmgr-for-i3/style.css (if any) on top. style.css is reloaded when
+// it changes, after which refresh is called so that row styles are updated.
+func initStyle(theme string, refresh func()) error {
+	themeCSS, ok := themes[theme]
+	if !ok {
+		return fmt.Errorf("unknown theme %q (expected system, light or dark)", theme)
+	}
+	screen, err := gdk.ScreenGetDefault()
+	if err != nil {
+		return err
+	}
+
+	builtin, err := gtk.CssProviderNew()
+	if err != nil {
+		return err
+	}
+	if err := builtin.LoadFromData(defaultCSS + themeCSS); err != nil {
+		return err
+	}
+	gtk.AddProviderForScreen(screen, builtin, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+	fn := filepath.Join(configDir, "wsmgr-for-i3", "style.css")
+	user, err := gtk.CssProviderNew()
+	if err != nil {
+		return err
+	}
+	gtk.AddProviderForScreen(screen, user, gtk.STYLE_PROVIDER_PRIORITY_USER)
+
+	var loaded time.Time // modification time of the loaded style.css
+	load := func() {
+		fi, err := os.Stat(fn)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Print(err)
+			}
+			if !loaded.IsZero() {
+				log.Printf("%s removed, unloading", fn)
+				user.LoadFromData("")
+				loaded = time.Time{}
+				refresh()
+			}
+			return
+		}
+		if fi.ModTime().Equal(loaded) {
+			return
+		}
+		loaded = fi.ModTime()
+		log.Printf("loading %s", fn)
+		// On error (e.g. a syntax error), GTK keeps the rules which were parsed
+		// successfully.
+		if err := user.LoadFromPath(fn); err != nil {
+			log.Print(err)
+		}
+		refresh()
+	}
+	load()
+	glib.TimeoutAdd(uint(styleReloadInterval/time.Millisecond), func() bool {
+		load()
+		return true // keep checking
+	})
+	return nil
+}
+
+// rowStyleColumns is the number of model columns containing rowStyle values.
+const rowStyleColumns = 6
+
+// rowStyleProperties are the CSS properties of the #wsmgr-row-<state> widgets
+// which are applied to rows, see rowStyle.
+var rowStyleProperties = []string{"color", "background-color", "font-weight"}
+
+// styleContext returns the style context of the (never shown) label named
+// name, which style.css can style like any other widget.
+func (w *wsmgr) styleContext(name string) *gtk.StyleContext {
+	label, ok := w.styleLabels[name]
+	if !ok {
+		var err error
+		label, err = gtk.LabelNew("")
+		if err != nil {
+			log.Fatal(err)
+		}
+		label.SetName(name)
+		if w.styleLabels == nil {
+			w.styleLabels = make(map[string]*gtk.Label)
+		}
+		w.styleLabels[name] = label
+	}
+	ctx, err := label.GetStyleContext()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return ctx
+}
+
+// cssValue returns the value of the CSS property of ctx as a color string or
+// a font weight, or nil if it cannot be read.
+func cssValue(ctx *gtk.StyleContext, property string) interface{} {
+	val, err := ctx.GetProperty2(property, gtk.STATE_FLAG_NORMAL)
+	if err != nil {
+		log.Printf("CSS property %s: %v", property, err)
+		return nil
+	}
+	switch val := val.(type) {
+	case *gdk.RGBA:
+		return val.String()
+	case int:
+		return val
+	}
+	return nil
+}
+
+// rowStyle returns the values for the foreground, foreground-set,
+// cell-background, cell-background-set, weight and weight-set cell renderer
+// properties of rows in the specified state. The values are taken from the
+// color, background-color and font-weight of the #wsmgr-row-<state> widget
+// (see css.go), unless they are the same as for #wsmgr-row (i.e. not styled
+// for the state). An empty state results in the default style.
+func (w *wsmgr) rowStyle(state string) []interface{} {
+	// Unset values need to be valid nevertheless, GTK warns otherwise.
+	const unsetColor = "#000000"
+	const unsetWeight = 400 // PANGO_WEIGHT_NORMAL
+	style := []interface{}{unsetColor, false, unsetColor, false, unsetWeight, false}
+	if state == "" {
+		return style
+	}
+	ctx := w.styleContext("wsmgr-row-" + state)
+	base := w.styleContext("wsmgr-row")
+	for i, property := range rowStyleProperties {
+		val := cssValue(ctx, property)
+		if val == nil || val == cssValue(base, property) {
+			continue
+		}
+		style[2*i], style[2*i+1] = val, true
+	}
+	return style
+}
+
+// addStyleAttributes binds the style properties of renderer to the
+// rowStyleColumns model columns starting at column, which contain rowStyle
+// values.
+func addStyleAttributes(tvc *gtk.TreeViewColumn, renderer gtk.ICellRenderer, column int) {
+	// Setting a property also enables it, so the *-set properties need to be
+	// applied last. GTK applies attributes in reverse order of addition.
+	tvc.AddAttribute(renderer, "foreground-set", column+1)
+	tvc.AddAttribute(renderer, "cell-background-set", column+3)
+	tvc.AddAttribute(renderer, "weight-set", column+5)
+	tvc.AddAttribute(renderer, "foreground", column)
+	tvc.AddAttribute(renderer, "cell-background", column+2)
+	tvc.AddAttribute(renderer, "weight", column+4)
+}
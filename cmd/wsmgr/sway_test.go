@@ -0,0 +1,363 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stapelberg/wsmgr-for-i3/internal/wm/wmtest"
+	"go.i3wm.org/i3/v4"
+)
+
+// The following tests drive wsmgr against a fake window manager which answers
+// like sway (see package wmtest).
+
+// withSway starts a fake sway, points wsmgr to it and to an empty
+// configuration directory, and returns both.
+func withSway(t *testing.T) (*wmtest.Server, string) {
+	t.Helper()
+	configDir := withConfigDir(t)
+	withNameFormat(t)
+	// Each fake has its own config.
+	i3ConfigCache.read = false
+	t.Cleanup(func() { i3ConfigCache.read = false })
+	s := wmtest.NewServer(t)
+	s.Install()
+	return s, configDir
+}
+
+func workspaceNames(s *wmtest.Server) []string {
+	var names []string
+	for _, ws := range s.Workspaces() {
+		names = append(names, ws.Name)
+	}
+	return names
+}
+
+// waitFor polls cond until it returns true, failing the test after 5 seconds.
+func waitFor(t *testing.T, desc string, cond func() bool) {
+	t.Helper()
+	for start := time.Now(); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("timeout waiting for %s", desc)
+		}
+	}
+}
+
+func TestSwayRenumber(t *testing.T) {
+	s, _ := withSway(t)
+	s.AddWorkspace("1: www", wmtest.Window{AppID: "firefox", PID: 1})
+	s.AddWorkspace("2: kint", wmtest.Window{AppID: "foot", PID: 2})
+	s.AddWorkspace("3: mail", wmtest.Window{AppID: "thunderbird", PID: 3})
+
+	workspaces, err := i3.GetWorkspaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Drag mail to the top.
+	order := []i3.Workspace{workspaces[2], workspaces[0], workspaces[1]}
+	if err := renumber(order); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := workspaceNames(s), []string{"2: www", "3: kint", "1: mail"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("workspaces after renumber = %q, want %q", got, want)
+	}
+
+	// Renumbering in the current order does not rename anything.
+	before := len(s.Commands())
+	workspaces, err = i3.GetWorkspaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	order = []i3.Workspace{workspaces[2], workspaces[0], workspaces[1]}
+	if err := renumber(order); err != nil {
+		t.Fatal(err)
+	}
+	if cmds := s.Commands()[before:]; len(cmds) > 0 {
+		t.Errorf("renumber in current order sent commands %q, want none", cmds)
+	}
+}
+
+func TestSwayRenumberInvalidNumbering(t *testing.T) {
+	s, configDir := withSway(t)
+	if err := ioutil.WriteFile(filepath.Join(configDir, "numbering"), []byte("policy sideways\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.AddWorkspace("1: www")
+	s.AddWorkspace("2: kint")
+
+	// Like adding a workspace, reordering falls back to the compact policy.
+	workspaces, err := i3.GetWorkspaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := renumber([]i3.Workspace{workspaces[1], workspaces[0]}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := workspaceNames(s), []string{"2: www", "1: kint"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("workspaces after renumber = %q, want %q", got, want)
+	}
+}
+
+// writeScript writes an executable shell script to fn.
+func writeScript(t *testing.T, fn, script string) {
+	t.Helper()
+	if err := ioutil.WriteFile(fn, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSwayLoadWorkspace(t *testing.T) {
+	s, configDir := withSway(t)
+	defer func(old time.Duration) { trackDuration = old }(trackDuration)
+	trackDuration = 2 * time.Second
+	defer trackers.Wait()
+
+	// The workspace kint starts a program in its working directory, which
+	// records its pid and environment, then opens a window (see below).
+	src := t.TempDir()
+	dir := filepath.Join(configDir, "kint")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(src, filepath.Join(dir, "cwd")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "env"), []byte("GREETING=hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeScript(t, filepath.Join(dir, "editor"), `echo "$GREETING" > greeting
+echo $$ > pid.tmp && mv pid.tmp pid
+exec sleep 10
+`)
+	// Setting files are not started, even if executable.
+	for _, name := range []string{"pinned", "icon", "color", "cwd-order", "urls"} {
+		writeScript(t, filepath.Join(dir, name), "touch "+name+"-started\n")
+	}
+
+	// Like the GUI, switch to the new workspace before loading it.
+	s.AddWorkspace("1: www", wmtest.Window{AppID: "firefox", PID: 1})
+	s.Focus("2: kint")
+	if err := loadWorkspace("kint"); err != nil {
+		t.Fatal(err)
+	}
+
+	var pid int
+	waitFor(t, "the program to start", func() bool {
+		b, err := ioutil.ReadFile(filepath.Join(src, "pid"))
+		if err != nil {
+			return false
+		}
+		pid, err = strconv.Atoi(strings.TrimSpace(string(b)))
+		return err == nil
+	})
+	defer syscall.Kill(pid, syscall.SIGTERM)
+	if b, err := ioutil.ReadFile(filepath.Join(src, "greeting")); err != nil {
+		t.Error(err)
+	} else if got, want := strings.TrimSpace(string(b)), "hello"; got != want {
+		t.Errorf("GREETING = %q, want %q", got, want)
+	}
+
+	// Before the program opens its window, the user switches back to www
+	// (which removes the empty workspace 2: kint), and another workspace
+	// takes its number.
+	s.Focus("1: www")
+	s.Focus("2: mail")
+	s.NewWindow(wmtest.Window{AppID: "thunderbird", PID: 3})
+
+	// The window opens on the focused workspace, and is moved to kint.
+	w := s.NewWindow(wmtest.Window{AppID: "emacs", PID: pid})
+	waitFor(t, "the window to be moved", func() bool {
+		for _, ws := range s.Workspaces() {
+			for _, win := range ws.Windows {
+				if win.ID == w.ID {
+					return ws.Name == "2: kint"
+				}
+			}
+		}
+		return false
+	})
+
+	// A reorder renames the workspace while programs are still starting:
+	// further windows follow it by id.
+	s.Rename("2: kint", "3: kint")
+	w = s.NewWindow(wmtest.Window{AppID: "emacs", PID: pid})
+	waitFor(t, "the second window to be moved", func() bool {
+		for _, ws := range s.Workspaces() {
+			for _, win := range ws.Windows {
+				if win.ID == w.ID {
+					return ws.Name == "3: kint"
+				}
+			}
+		}
+		return false
+	})
+	for _, name := range workspaceNames(s) {
+		if name == "2: kint" {
+			t.Errorf("stale workspace %q was re-created", name)
+		}
+	}
+
+	for _, name := range []string{"pinned", "icon", "color", "cwd-order", "urls"} {
+		if _, err := os.Stat(filepath.Join(src, name+"-started")); err == nil {
+			t.Errorf("setting file %s was started", name)
+		}
+	}
+
+	// Windows of other programs stay where they are.
+	other := s.NewWindow(wmtest.Window{AppID: "foot", PID: os.Getppid()})
+	time.Sleep(100 * time.Millisecond)
+	for _, ws := range s.Workspaces() {
+		for _, win := range ws.Windows {
+			if win.ID == other.ID && ws.Name != "2: mail" {
+				t.Errorf("unrelated window moved to %q", ws.Name)
+			}
+		}
+	}
+}
+
+func TestSwayRestore(t *testing.T) {
+	s, configDir := withSway(t)
+	defer func(old time.Duration) { trackDuration = old }(trackDuration)
+	trackDuration = 100 * time.Millisecond
+
+	if err := os.Mkdir(filepath.Join(configDir, "kint"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s.AddWorkspace("5: mail", wmtest.Window{AppID: "thunderbird", PID: 3})
+	s.AddWorkspace("7", wmtest.Window{AppID: "foot", PID: 4})
+
+	b, err := json.Marshal([]i3.Workspace{
+		{Num: 1, Name: "1: mail"},
+		{Num: 2, Name: "2:kint"}, // written with a different name format
+		{Num: 3, Name: "3"},      // numbered workspaces are not restored
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(configDir, "autosave.json"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := restore(true /* dryRun */); err != nil {
+		t.Fatal(err)
+	}
+	if cmds := s.Commands(); len(cmds) > 0 {
+		t.Errorf("dry run sent commands %q", cmds)
+	}
+
+	if err := restore(false /* dryRun */); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`rename workspace "5: mail" to "1: mail"`,
+		`workspace "2: kint"`,
+	}
+	if got := s.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("restore sent commands %q, want %q", got, want)
+	}
+	if got, want := workspaceNames(s), []string{"1: mail", "7", "2: kint"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("workspaces after restore = %q, want %q", got, want)
+	}
+}
+
+func TestSwayMenuLoad(t *testing.T) {
+	s, configDir := withSway(t)
+	defer func(old time.Duration) { trackDuration = old }(trackDuration)
+	trackDuration = 100 * time.Millisecond
+
+	for _, name := range []string{"kint", "mail"} {
+		if err := os.Mkdir(filepath.Join(configDir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.AddWorkspace("1: www", wmtest.Window{AppID: "firefox", PID: 1})
+	s.AddWorkspace("2: kint", wmtest.Window{AppID: "foot", PID: 2})
+
+	// kint is open already: switch to it instead of loading it again.
+	loaded, err := menuSelect("load: kint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded {
+		t.Errorf("menuSelect(load: kint) loaded the open workspace")
+	}
+	loaded, err = menuSelect("load: mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded {
+		t.Errorf("menuSelect(load: mail) did not load the workspace")
+	}
+	want := []string{
+		`workspace "2: kint"`,
+		`workspace "3: mail"`,
+	}
+	if got := s.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("menuSelect sent commands %q, want %q", got, want)
+	}
+	trackers.Wait()
+}
+
+func TestSwayNumberingIncludedConfig(t *testing.T) {
+	s, configDir := withSway(t)
+	if err := ioutil.WriteFile(filepath.Join(configDir, "numbering"), []byte("policy outputs\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.SetConfig("set $ext HDMI-1\nworkspace 1 output eDP-1\ninclude outputs.conf\n",
+		wmtest.IncludedConfig{
+			Path:       "/home/michael/.config/i3/outputs.conf",
+			RawContent: "workspace 11 output $ext\nworkspace 19 output $ext\n",
+		})
+	want := map[string]numberRange{
+		"eDP-1":  {lo: 1, hi: 1},
+		"HDMI-1": {lo: 11, hi: 19},
+	}
+	n, err := readNumbering()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(n.ranges, want) {
+		t.Errorf("readNumbering: ranges = %v, want %v", n.ranges, want)
+	}
+
+	// The i3 config is only read once.
+	s.SetConfig("")
+	n, err = readNumbering()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(n.ranges, want) {
+		t.Errorf("readNumbering (#2): ranges = %v, want %v", n.ranges, want)
+	}
+}
+
+func TestSwayColorMarkup(t *testing.T) {
+	for _, tt := range []struct {
+		config string
+		want   string
+	}{
+		{"font pango:DejaVu Sans Mono 10\n", "2: kint"},
+		{"bar {\n\tpango_markup enabled\n}\n", "2: <span foreground='orange'>kint</span>"},
+	} {
+		s, configDir := withSway(t)
+		s.SetConfig(tt.config)
+		if err := os.Mkdir(filepath.Join(configDir, "kint"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(configDir, "kint", "color"), []byte("orange\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		workspaces := []i3.Workspace{{Num: 1, Name: "1: www", Focused: true}}
+		if got := nextWorkspaceName(workspaces, "kint"); got != tt.want {
+			t.Errorf("config %q: nextWorkspaceName = %q, want %q", tt.config, got, tt.want)
+		}
+	}
+}
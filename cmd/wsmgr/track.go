@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stapelberg/wsmgr-for-i3/internal/proc"
+	"github.com/stapelberg/wsmgr-for-i3/internal/wm"
+	"go.i3wm.org/i3/v4"
+)
+
+// trackDuration is how long after loading a workspace new windows are checked
+// for whether they belong to the loaded workspace. Programs which take longer
+// than that to open their window will stay wherever i3 places them.
+var trackDuration = 30 * time.Second
+
+// trackers keeps track of running windowTrackers, so that the process can wait
+// for them before exiting.
+var trackers sync.WaitGroup
+
+// windowTracker moves windows which were opened by processes started by
+// loadWorkspace to the workspace that was being loaded, even if the user
+// switched to a different workspace in the meantime.
+//
+// Windows are attributed to a started process via their process id (the
+// _NET_WM_PID property on i3, see wm.PIDReader):
+// the window belongs to the workspace if its process is a descendant of a
+// started process, or is in the session of a started process (each started
+// process becomes a session leader, so that forked-off children are found even
+// after their parent exited). Windows which are created by an already-running
+// process (e.g. a new window of an already-running browser) cannot be
+// attributed.
+//
+// The workspace is identified by its id, so that windows follow it when it is
+// renamed (e.g. re-numbered) while loading. Its name is only used once the
+// workspace no longer exists (i3 removes empty workspaces when switching away),
+// to create it anew.
+type windowTracker struct {
+	workspaceID i3.NodeID
+	workspace   string // last known name
+	recv        *wm.WindowEvents
+
+	mu   sync.Mutex
+	pids map[int]bool
+}
+
+// trackWindows starts tracking new windows for the specified workspace, until
+// trackDuration has elapsed. Processes must only be started (see add) once
+// trackWindows returned, so that none of their windows is missed.
+func trackWindows(ws i3.Workspace) *windowTracker {
+	t := &windowTracker{
+		workspaceID: i3.NodeID(ws.ID),
+		workspace:   ws.Name,
+		pids:        make(map[int]bool),
+	}
+	recv, err := wm.SubscribeWindowEvents()
+	if err != nil {
+		log.Printf("not tracking windows for workspace %q: %v", ws.Name, err)
+		return t
+	}
+	t.recv = recv
+	trackers.Add(1)
+	go func() {
+		defer trackers.Done()
+		t.run()
+	}()
+	time.AfterFunc(trackDuration, func() {
+		t.recv.Close()
+	})
+	return t
+}
+
+// add registers the process id of a started process.
+func (t *windowTracker) add(pid int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pids[pid] = true
+}
+
+// belongs returns whether the process with the specified pid was (directly or
+// indirectly) started by one of the registered processes.
+func (t *windowTracker) belongs(pid int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for pid > 1 {
+		if t.pids[pid] {
+			return true
+		}
+		st, err := proc.ReadStat(pid)
+		if err != nil {
+			return false
+		}
+		if t.pids[st.Session] {
+			return true
+		}
+		pid = st.PPID
+	}
+	return false
+}
+
+func (t *windowTracker) run() {
+	pids, err := wm.NewPIDReader()
+	if err != nil {
+		log.Printf("not tracking windows for workspace %q: %v", t.workspace, err)
+		return
+	}
+	defer pids.Close()
+	for t.recv.Next() {
+		ev := t.recv.Event()
+		if ev.Change != "new" {
+			continue
+		}
+		pid, err := pids.EventPID(ev)
+		if err != nil {
+			continue // cannot attribute the window
+		}
+		if !t.belongs(pid) {
+			continue
+		}
+		tree, err := i3.GetTree()
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		target := tree.Root.FindChild(func(n *i3.Node) bool {
+			return n.Type == i3.WorkspaceNode && n.ID == t.workspaceID
+		})
+		if target != nil {
+			t.workspace = target.Name // might have been renamed
+		}
+		ws := workspaceOf(tree.Root, ev.Container.ID)
+		if ws == nil || ws.ID == t.workspaceID || ws.Name == t.workspace {
+			continue
+		}
+		cmd := fmt.Sprintf(`[con_id=%d] move container to workspace "%s"`, ev.Container.ID, t.workspace)
+		log.Printf("moving window of pid %d: %q", pid, cmd)
+		if _, err := wm.RunCommand(cmd); err != nil {
+			log.Print(err)
+			continue
+		}
+		if target == nil {
+			// The move created the workspace anew: follow the new one.
+			if err := t.refreshID(); err != nil {
+				log.Print(err)
+			}
+		}
+	}
+}
+
+// refreshID updates the workspace id after the workspace was created anew.
+func (t *windowTracker) refreshID() error {
+	workspaces, err := i3.GetWorkspaces()
+	if err != nil {
+		return err
+	}
+	for _, ws := range workspaces {
+		if ws.Name == t.workspace {
+			t.workspaceID = i3.NodeID(ws.ID)
+			return nil
+		}
+	}
+	return fmt.Errorf("workspace %q not found", t.workspace)
+}
+
+// workspaceOf returns the workspace which contains the node with the specified
+// id, or nil if there is no such node (or it is not on a workspace, e.g. in the
+// scratchpad).
+func workspaceOf(root *i3.Node, id i3.NodeID) *i3.Node {
+	ws := root.FindChild(func(n *i3.Node) bool {
+		return n.Type == i3.WorkspaceNode && n.FindChild(func(n *i3.Node) bool {
+			return n.ID == id
+		}) != nil
+	})
+	if ws != nil && strings.HasPrefix(ws.Name, "__") {
+		return nil // e.g. __i3_scratch
+	}
+	return ws
+}
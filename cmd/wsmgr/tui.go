@@ -0,0 +1,482 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"github.com/stapelberg/wsmgr-for-i3/internal/term"
+	"github.com/stapelberg/wsmgr-for-i3/internal/wsname"
+	"go.i3wm.org/i3/v4"
+)
+
+// tuiHelp is displayed in the last line of the terminal.
+const tuiHelp = "↑↓ select  ⏎ switch/load  J/K move  r rename  a add  q quit"
+
+// tui is the terminal frontend. It offers the same operations as the GTK
+// window, but does not need cgo.
+type tui struct {
+	tty  *os.File
+	quit bool // exit after switching to or loading a workspace
+
+	workspaces []i3.Workspace
+	configs    []configInfo // configured workspaces which are not open
+	cursor     int          // index into workspaces, then configs
+	status     string       // result of the last action
+
+	// While prompting (renaming or adding a workspace), prompt is non-empty
+	// and submit is called with the entered text.
+	prompt string
+	input  []rune
+	submit func(string) error
+}
+
+// refresh reads the open and configured workspaces. The cursor stays on the
+// selected workspace, if it still exists.
+func (t *tui) refresh() error {
+	var selectedID i3.WorkspaceID
+	var selectedConfig string
+	if t.cursor < len(t.workspaces) {
+		selectedID = t.workspaces[t.cursor].ID
+	} else if idx := t.cursor - len(t.workspaces); idx < len(t.configs) {
+		selectedConfig = t.configs[idx].Name
+	}
+
+	workspaces, err := i3.GetWorkspaces()
+	if err != nil {
+		return err
+	}
+	names, err := configuredWorkspaces()
+	if err != nil {
+		return err
+	}
+	open := make(map[string]bool)
+	for _, ws := range workspaces {
+		open[nameWithoutNumberPrefix(ws)] = true
+	}
+	var configs []configInfo
+	for _, name := range names {
+		if open[name] {
+			continue
+		}
+		info, err := inspectConfig(name)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		configs = append(configs, info)
+	}
+	t.workspaces = workspaces
+	t.configs = configs
+
+	for idx, ws := range t.workspaces {
+		if selectedID != 0 && ws.ID == selectedID {
+			t.cursor = idx
+		}
+	}
+	for idx, info := range t.configs {
+		if selectedConfig != "" && info.Name == selectedConfig {
+			t.cursor = len(t.workspaces) + idx
+		}
+	}
+	if t.cursor >= len(t.workspaces)+len(t.configs) {
+		t.cursor = len(t.workspaces) + len(t.configs) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+	return nil
+}
+
+// truncate cuts s to width runes.
+func truncate(s string, width int) string {
+	if width < 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
+
+// draw renders the workspace lists, scrolled so that the cursor is visible.
+func (t *tui) draw() error {
+	cols, rows, err := term.Size(int(t.tty.Fd()))
+	if err != nil {
+		return err
+	}
+
+	type line struct {
+		text  string
+		attrs string // SGR parameters
+	}
+	var lines []line
+	cursorLine := 0
+	lines = append(lines, line{text: "open workspaces", attrs: "1;4"})
+	for idx, ws := range t.workspaces {
+		var attrs []string
+		switch {
+		case ws.Urgent:
+			attrs = append(attrs, "41")
+		case ws.Focused:
+			attrs = append(attrs, "1")
+		case ws.Visible:
+			attrs = append(attrs, "4")
+		}
+		if idx == t.cursor {
+			cursorLine = len(lines)
+			attrs = append(attrs, "7")
+		}
+		lines = append(lines, line{
+			text:  fmt.Sprintf(" %-30s %s", wsname.StripMarkup(ws.Name), ws.Output),
+			attrs: strings.Join(attrs, ";"),
+		})
+	}
+	lines = append(lines, line{})
+	lines = append(lines, line{text: "load workspace…", attrs: "1;4"})
+	for idx, info := range t.configs {
+		warning := " "
+		if len(info.Warnings) > 0 {
+			warning = "!"
+		}
+		var attrs string
+		if len(t.workspaces)+idx == t.cursor {
+			cursorLine = len(lines)
+			attrs = "7"
+		}
+		lines = append(lines, line{
+			text:  fmt.Sprintf("%s%-30s %-40s %s", warning, info.Name, info.Summary(), info.LastLoadString()),
+			attrs: attrs,
+		})
+	}
+
+	// The last two lines are the status (or prompt) and the help.
+	height := rows - 2
+	offset := 0
+	if cursorLine >= height {
+		offset = cursorLine - height + 1
+	}
+	var b strings.Builder
+	b.WriteString("\x1b[?25l\x1b[H") // hide cursor, move to top left
+	for row := 0; row < height; row++ {
+		if idx := offset + row; idx < len(lines) {
+			l := lines[idx]
+			if l.attrs != "" {
+				b.WriteString("\x1b[" + l.attrs + "m")
+			}
+			b.WriteString(truncate(l.text, cols))
+			b.WriteString("\x1b[0m")
+		}
+		b.WriteString("\x1b[K\r\n") // clear to end of line
+	}
+	if t.prompt != "" {
+		prompt := t.prompt + ": " + string(t.input)
+		b.WriteString(truncate(prompt, cols))
+		b.WriteString("\x1b[K\r\n")
+		b.WriteString("\x1b[2m" + truncate("⏎ confirm  esc cancel", cols) + "\x1b[0m\x1b[K")
+		col := utf8.RuneCountInString(prompt) + 1
+		if col > cols {
+			col = cols
+		}
+		fmt.Fprintf(&b, "\x1b[%d;%dH\x1b[?25h", height+1, col) // show cursor
+	} else {
+		b.WriteString(truncate(t.status, cols))
+		b.WriteString("\x1b[K\r\n")
+		b.WriteString("\x1b[2m" + truncate(tuiHelp, cols) + "\x1b[0m\x1b[K")
+	}
+	_, err = io.WriteString(t.tty, b.String())
+	return err
+}
+
+// startPrompt reads a line of text, prefilled with initial.
+func (t *tui) startPrompt(prompt, initial string, submit func(string) error) {
+	t.prompt = prompt
+	t.input = []rune(initial)
+	t.submit = submit
+}
+
+// activate switches to the selected open workspace, or loads the selected
+// configured workspace (like the GUI, numbered according to the numbering
+// policy, see nextWorkspaceName).
+func (t *tui) activate() error {
+	if t.cursor < len(t.workspaces) {
+		ws := t.workspaces[t.cursor]
+		_, err := i3.RunCommand(fmt.Sprintf(`workspace "%s"`, ws.Name))
+		return err
+	}
+	idx := t.cursor - len(t.workspaces)
+	if idx >= len(t.configs) {
+		return nil
+	}
+	name := t.configs[idx].Name
+	newName := nextWorkspaceName(t.workspaces, name)
+	if _, err := i3.RunCommand(fmt.Sprintf(`workspace "%s"`, newName)); err != nil {
+		return err
+	}
+	return loadWorkspace(name)
+}
+
+// move moves the selected workspace up (delta -1) or down (delta 1) and
+// renumbers all workspaces accordingly.
+func (t *tui) move(delta int) error {
+	other := t.cursor + delta
+	if t.cursor >= len(t.workspaces) || other < 0 || other >= len(t.workspaces) {
+		return nil
+	}
+	order := append([]i3.Workspace(nil), t.workspaces...)
+	order[t.cursor], order[other] = order[other], order[t.cursor]
+	// refresh keeps the cursor on the moved workspace.
+	return renumber(order)
+}
+
+// handleKey performs the action for key and returns whether to exit.
+func (t *tui) handleKey(key string) (exit bool, _ error) {
+	if t.prompt != "" {
+		switch key {
+		case "enter":
+			submit, input := t.submit, string(t.input)
+			t.prompt = ""
+			return false, submit(input)
+		case "esc", "ctrl-c":
+			t.prompt = ""
+		case "backspace":
+			if len(t.input) > 0 {
+				t.input = t.input[:len(t.input)-1]
+			}
+		case "ctrl-u":
+			t.input = nil
+		default:
+			if utf8.RuneCountInString(key) == 1 {
+				t.input = append(t.input, []rune(key)...)
+			}
+		}
+		return false, nil
+	}
+
+	switch key {
+	case "q", "esc", "ctrl-c":
+		return true, nil
+	case "up", "k":
+		if t.cursor > 0 {
+			t.cursor--
+		}
+	case "down", "j":
+		if t.cursor < len(t.workspaces)+len(t.configs)-1 {
+			t.cursor++
+		}
+	case "shift-up", "K":
+		return false, t.move(-1)
+	case "shift-down", "J":
+		return false, t.move(1)
+	case "enter":
+		if err := t.activate(); err != nil {
+			return false, err
+		}
+		return t.quit, nil
+	case "r":
+		if t.cursor >= len(t.workspaces) {
+			return false, nil
+		}
+		ws := t.workspaces[t.cursor]
+		t.startPrompt("rename "+wsname.StripMarkup(ws.Name), nameWithoutNumberPrefix(ws), func(newName string) error {
+			newName = keepNumberPrefix(ws, newName)
+			cmd := fmt.Sprintf(`rename workspace "%s" to "%s"`, ws.Name, newName)
+			log.Printf("renaming workspace: %q", cmd)
+			_, err := i3.RunCommand(cmd)
+			return err
+		})
+	case "a":
+		t.startPrompt("add workspace", "unnamed", func(name string) error {
+			newName := nextWorkspaceName(t.workspaces, name)
+			_, err := i3.RunCommand(fmt.Sprintf(`workspace "%s"`, newName))
+			return err
+		})
+	}
+	return false, nil
+}
+
+// csiKeys maps the parameters and final byte of CSI (and SS3) escape
+// sequences to key names.
+var csiKeys = map[string]string{
+	"A":    "up",
+	"B":    "down",
+	"1;2A": "shift-up",
+	"1;2B": "shift-down",
+}
+
+// parseKeys splits terminal input into key names (see handleKey), or the
+// typed character. An escape sequence or character which is split across reads
+// is returned as rest, to be parsed together with the next read.
+func parseKeys(b []byte) (keys []string, rest []byte) {
+	for len(b) > 0 {
+		switch {
+		case b[0] == 0x1b && len(b) > 1 && (b[1] == '[' || b[1] == 'O'):
+			end := 2
+			for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+				end++
+			}
+			if end == len(b) {
+				return keys, b // incomplete sequence
+			}
+			if key, ok := csiKeys[string(b[2:end+1])]; ok {
+				keys = append(keys, key)
+			}
+			b = b[end+1:]
+		case b[0] == 0x1b && len(b) > 1:
+			b = b[2:] // alt+key, not supported
+		case b[0] == 0x1b:
+			// An escape sequence is written at once, so a trailing ESC is
+			// the escape key.
+			keys = append(keys, "esc")
+			b = b[1:]
+		case b[0] == '\r' || b[0] == '\n':
+			keys = append(keys, "enter")
+			b = b[1:]
+		case b[0] == 0x7f || b[0] == 0x08:
+			keys = append(keys, "backspace")
+			b = b[1:]
+		case b[0] == 0x03:
+			keys = append(keys, "ctrl-c")
+			b = b[1:]
+		case b[0] == 0x15:
+			keys = append(keys, "ctrl-u")
+			b = b[1:]
+		case b[0] < 0x20:
+			b = b[1:] // other control characters
+		case !utf8.FullRune(b):
+			return keys, b // incomplete character
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, string(r))
+			b = b[size:]
+		}
+	}
+	return keys, nil
+}
+
+// runTUI runs the terminal frontend until the user quits.
+func runTUI(quit bool) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+
+	// Log messages and the output of programs started when loading a
+	// workspace would garble the display, so they go to a log file.
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return err
+	}
+	logFn := filepath.Join(cacheDir, "wsmgr-for-i3", "tui.log")
+	if err := os.MkdirAll(filepath.Dir(logFn), 0755); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(logFn, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = logFile, logFile
+	log.SetOutput(logFile)
+	defer func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		log.SetOutput(stderr)
+	}()
+
+	state, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return err
+	}
+	io.WriteString(tty, "\x1b[?1049h") // alternate screen
+	defer func() {
+		io.WriteString(tty, "\x1b[?25h\x1b[?1049l") // show cursor, main screen
+		term.Restore(int(tty.Fd()), state)
+	}()
+
+	keys := make(chan string)
+	go func() {
+		buf := make([]byte, 256)
+		var rest []byte // incomplete input of the previous read
+		for {
+			n, err := tty.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			var parsed []string
+			parsed, rest = parseKeys(append(rest, buf[:n]...))
+			rest = append([]byte(nil), rest...)
+			for _, key := range parsed {
+				keys <- key
+			}
+		}
+	}()
+	changed := make(chan struct{}, 1)
+	go func() {
+		recv := i3.Subscribe(i3.WorkspaceEventType, i3.OutputEventType)
+		for recv.Next() {
+			select {
+			case changed <- struct{}{}:
+			default: // refresh already pending
+			}
+		}
+	}()
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+
+	t := &tui{tty: tty, quit: quit}
+	if err := t.refresh(); err != nil {
+		return err
+	}
+	for {
+		if err := t.draw(); err != nil {
+			return err
+		}
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return nil // terminal closed
+			}
+			exit, err := t.handleKey(key)
+			t.status = ""
+			if err != nil {
+				log.Print(err)
+				t.status = err.Error()
+			}
+			if exit {
+				return nil
+			}
+		case <-changed:
+		case <-winch:
+		}
+		if err := t.refresh(); err != nil {
+			return err
+		}
+	}
+}
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "terminal frontend",
+	Long:  "do not show the GUI, instead manage workspaces in the terminal (does not require cgo)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := runTUI(tuiQuit); err != nil {
+			return err
+		}
+		// Keep running until all windows of loaded workspaces were placed.
+		trackers.Wait()
+		return nil
+	},
+}
+
+var tuiQuit bool
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"go.i3wm.org/i3/v4"
+)
+
+func TestParseKeys(t *testing.T) {
+	for _, tt := range []struct {
+		input    string
+		want     []string
+		wantRest string
+	}{
+		{input: "jk\r", want: []string{"j", "k", "enter"}},
+		{input: "\x1b[A\x1b[B\x1bOA", want: []string{"up", "down", "up"}},
+		{input: "\x1b[1;2A\x1b[1;2B", want: []string{"shift-up", "shift-down"}},
+		{input: "\x7f\x03\x15", want: []string{"backspace", "ctrl-c", "ctrl-u"}},
+		{input: "ä€", want: []string{"ä", "€"}},
+		// Unknown sequences, alt+key and other control characters are skipped.
+		{input: "\x1b[5~\x1bxq\x01", want: []string{"q"}},
+		{input: "\x1b", want: []string{"esc"}},
+
+		// Input which is split across reads is kept for the next read.
+		{input: "j\x1b[", want: []string{"j"}, wantRest: "\x1b["},
+		{input: "\x1bO", wantRest: "\x1bO"},
+		{input: "\x1b[1;2", wantRest: "\x1b[1;2"},
+		{input: "a\xc3", want: []string{"a"}, wantRest: "\xc3"},
+	} {
+		got, rest := parseKeys([]byte(tt.input))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseKeys(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+		if string(rest) != tt.wantRest {
+			t.Errorf("parseKeys(%q) rest = %q, want %q", tt.input, rest, tt.wantRest)
+		}
+	}
+}
+
+func TestParseKeysSplit(t *testing.T) {
+	var keys []string
+	var rest []byte
+	for _, read := range []string{"\x1b[1", ";2A\xe2", "\x82\xac\x1b[", "B"} {
+		var parsed []string
+		parsed, rest = parseKeys(append(rest, read...))
+		keys = append(keys, parsed...)
+	}
+	if want := []string{"shift-up", "€", "down"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %q, want %q", keys, want)
+	}
+	if len(rest) > 0 {
+		t.Errorf("rest = %q, want none", rest)
+	}
+}
+
+func TestHandleKey(t *testing.T) {
+	withNameFormat(t)
+	tui := &tui{
+		workspaces: []i3.Workspace{
+			{ID: 1, Num: 1, Name: "1: www"},
+			{ID: 2, Num: 2, Name: "2: kint"},
+		},
+		configs: []configInfo{{Name: "mail"}},
+	}
+	press := func(keys ...string) bool {
+		t.Helper()
+		for _, key := range keys {
+			exit, err := tui.handleKey(key)
+			if err != nil {
+				t.Fatalf("handleKey(%q) = %v", key, err)
+			}
+			if exit {
+				return true
+			}
+		}
+		return false
+	}
+
+	// The cursor moves across open and configured workspaces, but not past
+	// the ends of the list.
+	press("down", "j", "j")
+	if got, want := tui.cursor, 2; got != want {
+		t.Errorf("cursor = %d, want %d", got, want)
+	}
+	press("up", "k", "k")
+	if got, want := tui.cursor, 0; got != want {
+		t.Errorf("cursor = %d, want %d", got, want)
+	}
+
+	// Renaming prompts for the name without number.
+	press("j", "r")
+	if got, want := tui.prompt, "rename 2: kint"; got != want {
+		t.Errorf("prompt = %q, want %q", got, want)
+	}
+	if got, want := string(tui.input), "kint"; got != want {
+		t.Errorf("input = %q, want %q", got, want)
+	}
+	// Keys edit the input while prompting, q does not quit.
+	press("backspace", "backspace", "x", "q")
+	if got, want := string(tui.input), "kixq"; got != want {
+		t.Errorf("input = %q, want %q", got, want)
+	}
+	press("ctrl-u", "m", "a", "i", "l")
+	var submitted string
+	tui.submit = func(input string) error {
+		submitted = input
+		return nil
+	}
+	press("enter")
+	if got, want := submitted, "mail"; got != want {
+		t.Errorf("submitted %q, want %q", got, want)
+	}
+	if tui.prompt != "" {
+		t.Errorf("still prompting after enter")
+	}
+
+	// Escape cancels the prompt without submitting.
+	submitted = ""
+	press("a")
+	if got, want := tui.prompt, "add workspace"; got != want {
+		t.Errorf("prompt = %q, want %q", got, want)
+	}
+	tui.submit = func(input string) error {
+		submitted = input
+		return nil
+	}
+	if press("esc") {
+		t.Errorf("esc while prompting exits, want cancel")
+	}
+	if tui.prompt != "" || submitted != "" {
+		t.Errorf("esc did not cancel the prompt (prompt %q, submitted %q)", tui.prompt, submitted)
+	}
+
+	// Renaming applies only to open workspaces.
+	press("j")
+	press("r")
+	if tui.prompt != "" {
+		t.Errorf("prompting to rename configured workspace")
+	}
+
+	if !press("q") {
+		t.Errorf("q does not exit")
+	}
+}
+
+func TestSwayHandleKey(t *testing.T) {
+	s, _ := withSway(t)
+	s.AddWorkspace("1: www")
+	s.AddWorkspace("2: kint")
+	s.AddWorkspace("3: mail")
+
+	tui := &tui{}
+	if err := tui.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	press := func(key string) {
+		t.Helper()
+		if _, err := tui.handleKey(key); err != nil {
+			t.Fatalf("handleKey(%q) = %v", key, err)
+		}
+		if err := tui.refresh(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Moving renumbers, the cursor stays on the moved workspace.
+	press("G") // not bound
+	press("j")
+	press("j")
+	press("K")
+	if got, want := workspaceNames(s), []string{"1: www", "3: kint", "2: mail"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("workspaces after K = %q, want %q", got, want)
+	}
+	if got, want := tui.workspaces[tui.cursor].Name, "2: mail"; got != want {
+		t.Errorf("cursor on %q, want %q", got, want)
+	}
+	// The first workspace cannot be moved up.
+	press("k")
+	press("k")
+	press("K")
+	if got, want := workspaceNames(s), []string{"1: www", "3: kint", "2: mail"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("workspaces after K on the first workspace = %q, want %q", got, want)
+	}
+
+	press("enter")
+	if got, want := s.Commands()[len(s.Commands())-1], `workspace "1: www"`; got != want {
+		t.Errorf("last command = %q, want %q", got, want)
+	}
+}
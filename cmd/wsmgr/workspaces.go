@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/renameio/v2"
+	"github.com/stapelberg/wsmgr-for-i3/internal/cwd"
+	"github.com/stapelberg/wsmgr-for-i3/internal/envfile"
+	"github.com/stapelberg/wsmgr-for-i3/internal/wsname"
+	"go.i3wm.org/i3/v4"
+)
+
+func loadWorkspace(name string) error {
+	log.Printf("Loading workspace %q", name)
+	if err := recordLoad(name); err != nil {
+		log.Print(err)
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(configDir, "wsmgr-for-i3", name)
+	order, err := cwd.Order(dir)
+	if err != nil {
+		log.Printf("workspace %q: %v", name, err)
+		order = cwd.DefaultOrder
+	}
+	r := &cwd.Resolver{Dir: dir}
+	cwd, err := r.Resolve(order)
+	if err != nil {
+		// e.g. a dangling cwd symlink: start the programs in the current
+		// working directory instead.
+		log.Printf("workspace %q: %v", name, err)
+		cwd = ""
+	}
+	env, err := envfile.Apply(os.Environ(), filepath.Join(dir, "env"))
+	if err != nil {
+		log.Printf("workspace %q: %v", name, err)
+		env = os.Environ()
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	// Both callers switch to the workspace before loading it, so the focused
+	// workspace is where windows of the started programs belong.
+	target, err := focusedWorkspace()
+	if err != nil {
+		return err
+	}
+	tracker := trackWindows(target)
+	start := func(cmd *exec.Cmd) {
+		cmd.Env = env
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		// Start a new session so that windows of children are attributed to
+		// this workspace even after their parent process exited.
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+		if err := cmd.Start(); err != nil {
+			log.Printf("%v: %v", cmd.Args, err)
+			return
+		}
+		tracker.add(cmd.Process.Pid)
+		go func() {
+			if err := cmd.Wait(); err != nil {
+				log.Printf("%v: %v", cmd.Args, err)
+			}
+		}()
+	}
+
+	for _, fi := range fis {
+		if fi.Mode().IsDir() && (fi.Name() == "." || fi.Name() == "..") {
+			continue
+		}
+
+		path := filepath.Join(dir, fi.Name())
+
+		executable := fi.Mode()&0100 != 0
+		symlink := fi.Mode()&os.ModeSymlink != 0
+		if symlink {
+			fi, err := os.Stat(path)
+			if err != nil {
+				log.Print(err)
+				continue
+			}
+			executable = fi.Mode()&0100 != 0
+			if fi.Mode().IsDir() {
+				executable = false
+			}
+		}
+		if executable && !settingFiles[fi.Name()] {
+			log.Printf("starting executable %s", path)
+			// File executable by its owner, try to execute it
+			cmd := exec.Command(path)
+			if cwd != "" {
+				cmd.Dir = cwd
+			}
+			start(cmd)
+		}
+
+		if fi.Name() == "chrome-rewindow" {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				log.Print(err)
+				continue
+			}
+			// The first line names the bookmark folder, each further line
+			// is a wsmgr-chrome-rewindow flag, e.g. -profile=Work.
+			var args []string
+			for _, line := range strings.Split(string(b), "\n") {
+				line = strings.TrimSpace(line)
+				if line == "" || strings.HasPrefix(line, "#") {
+					continue
+				}
+				if args == nil {
+					line = "-name=" + line
+				}
+				args = append(args, line)
+			}
+			if args == nil {
+				log.Printf("%s: no bookmark folder specified", path)
+				continue
+			}
+			start(exec.Command("wsmgr-chrome-rewindow", args...))
+		}
+
+		if fi.Name() == "urls" {
+			start(exec.Command("wsmgr-chrome-rewindow", "-urls="+path, "-workspace="+name, "-cwd="+cwd))
+		}
+	}
+	return nil
+}
+
+func focusedWorkspace() (i3.Workspace, error) {
+	workspaces, err := i3.GetWorkspaces()
+	if err != nil {
+		return i3.Workspace{}, err
+	}
+	for _, ws := range workspaces {
+		if ws.Focused {
+			return ws, nil
+		}
+	}
+	return i3.Workspace{}, fmt.Errorf("no focused workspace found")
+}
+
+func autosave() error {
+	workspaces, err := i3.GetWorkspaces()
+	if err != nil {
+		log.Fatal(err)
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+	autosaveFile := filepath.Join(configDir, "wsmgr-for-i3", "autosave.json")
+	f, err := renameio.TempFile("", autosaveFile)
+	if err != nil {
+		return err
+	}
+	defer f.Cleanup()
+	b, err := json.Marshal(workspaces)
+	if err != nil {
+		return err
+	}
+	f.Write(b)
+	return f.CloseAtomicallyReplace()
+}
+
+// nameFormat formats and parses workspace names (see loadNameFormat). It is
+// loaded before running any command.
+var nameFormat *wsname.Format
+
+// nameWithoutNumberPrefix returns the name of workspace ws without its number
+// (and icon), i.e. the name of its configuration directory. Numbered workspaces
+// (e.g. 4) are returned as is.
+func nameWithoutNumberPrefix(ws i3.Workspace) string {
+	if p := nameFormat.Parse(ws.Name); p.Name != "" {
+		return p.Name
+	}
+	// Numbered workspace
+	return ws.Name
+}
+
+// groupByOutput groups workspaces by output, in the order in which the outputs
+// first appear. Within an output, workspaces keep their order.
+func groupByOutput(workspaces []i3.Workspace) [][]i3.Workspace {
+	var groups [][]i3.Workspace
+	index := make(map[string]int)
+	for _, ws := range workspaces {
+		idx, ok := index[ws.Output]
+		if !ok {
+			idx = len(groups)
+			index[ws.Output] = idx
+			groups = append(groups, nil)
+		}
+		groups[idx] = append(groups[idx], ws)
+	}
+	return groups
+}
+
+// decorated returns the parts of the i3 name of workspace name with number num,
+// including the configured icon and color (see readIcon and readColor). The
+// color is only included if the bar renders it (see colorMarkup).
+func decorated(num int64, name string) wsname.Parts {
+	p := wsname.Parts{
+		Num:  num,
+		Name: name,
+	}
+	if color := workspaceColor(name); color != "" && colorMarkup() {
+		p.Color = color
+	}
+	p.Icon, _ = workspaceIcon(name) // images are only displayed in wsmgr
+	return p
+}
+
+// nextWorkspaceName returns the name for a new workspace called name, numbered
+// according to the configured numbering policy (see readNumbering). Numbers of
+// pinned workspaces (see pinnedWorkspaces) are skipped, and a pinned workspace
+// with a configured number gets that number.
+func nextWorkspaceName(workspaces []i3.Workspace, name string) string {
+	pinned, err := pinnedWorkspaces()
+	if err != nil {
+		log.Print(err)
+	}
+	num := pinned[name]
+	if num == 0 {
+		num = numberingOrDefault().next(workspaces, pinned)
+	}
+	return nameFormat.Format(decorated(num, name))
+}
+
+// pinnedNumbers returns the numbers which belong to pinned workspaces: their
+// configured numbers, and the current numbers of open pinned workspaces.
+func pinnedNumbers(workspaces []i3.Workspace, pinned map[string]int64) map[int64]bool {
+	taken := make(map[int64]bool)
+	for _, num := range pinned {
+		if num > 0 {
+			taken[num] = true
+		}
+	}
+	for _, ws := range workspaces {
+		if num, ok := pinned[nameWithoutNumberPrefix(ws)]; ok && num == 0 {
+			taken[ws.Num] = true
+		}
+	}
+	return taken
+}
+
+// keepNumberPrefix returns newName prefixed with the number of workspace ws
+// (unless newName already starts with a number), so that renaming a workspace
+// keeps its position. The icon and color of the new name are added, too.
+func keepNumberPrefix(ws i3.Workspace, newName string) string {
+	p := nameFormat.Parse(newName)
+	if p.Name == "" {
+		return newName // numbered workspace
+	}
+	if p.Num < 0 {
+		p.Num = ws.Num
+	}
+	return nameFormat.Format(decorated(p.Num, p.Name))
+}
+
+// renumber renames the workspaces so that they are numbered in the specified
+// order according to the configured numbering policy (see numberingOrDefault),
+// keeping their names.
+func renumber(order []i3.Workspace) error {
+	pinned, err := pinnedWorkspaces()
+	if err != nil {
+		return err
+	}
+	nums := numberingOrDefault().renumber(order, pinned)
+
+	for idx, ws := range order {
+		num := nums[idx]
+		log.Printf("  ws = %+v", ws)
+		if ws.Num == num {
+			continue // no rename required
+		}
+		oldName := ws.Name
+		p := nameFormat.Parse(ws.Name)
+		p.Num = num
+		ws.Name = nameFormat.Format(p)
+		rename := fmt.Sprintf(`rename workspace "%s" to "%s"`, oldName, ws.Name)
+		log.Printf("  -> rename=%q", rename)
+		if _, err := i3.RunCommand(rename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func makeItSo(dryRun bool, current, desired []i3.Workspace) error {
+	currentByName := make(map[string]i3.Workspace)
+	for _, ws := range current {
+		currentByName[nameWithoutNumberPrefix(ws)] = ws
+	}
+
+	for _, ws := range desired {
+		p := nameFormat.Parse(ws.Name)
+		if p.Name == "" {
+			continue // numbered workspace
+		}
+		name := p.Name
+		// The autosave file might have been written with a different name
+		// format (or decoration), so format the name anew.
+		ws.Name = nameFormat.Format(decorated(ws.Num, name))
+		current, ok := currentByName[name]
+		if !ok {
+			if dryRun {
+				log.Printf("dry-run: load workspace %q to %q", name, ws.Name)
+				continue
+			}
+
+			cmd := fmt.Sprintf(`workspace "%s"`, ws.Name)
+			if _, err := i3.RunCommand(cmd); err != nil {
+				log.Fatal(err)
+			}
+			if err := loadWorkspace(name); err != nil {
+				log.Printf("loading workspace %q failed: %v", name, err)
+			}
+			time.Sleep(1 * time.Second) // TODO: remove this sleep if nothing breaks
+			continue
+		}
+
+		if current.Name != ws.Name {
+			// Workspace exists, but has the wrong number (or name format),
+			// rename it.
+			cmd := fmt.Sprintf(`rename workspace "%s" to "%s"`, current.Name, ws.Name)
+			if dryRun {
+				log.Printf("dry-run: %s", cmd)
+				continue
+			}
+
+			if _, err := i3.RunCommand(cmd); err != nil {
+				return err
+			}
+
+			continue
+		}
+	}
+	return nil
+}
+
+func restore(dryRun bool) error {
+	currentWorkspaces, err := i3.GetWorkspaces()
+	if err != nil {
+		log.Fatal(err)
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+	autosaveFile := filepath.Join(configDir, "wsmgr-for-i3", "autosave.json")
+	b, err := ioutil.ReadFile(autosaveFile)
+	if err != nil {
+		return err
+	}
+	var desiredWorkspaces []i3.Workspace
+	if err := json.Unmarshal(b, &desiredWorkspaces); err != nil {
+		return err
+	}
+
+	if err := makeItSo(dryRun, currentWorkspaces, desiredWorkspaces); err != nil {
+		return err
+	}
+
+	// Keep running until all windows of the loaded workspaces were placed.
+	trackers.Wait()
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.i3wm.org/i3/v4"
+)
+
+func TestGroupByOutput(t *testing.T) {
+	workspaces := []i3.Workspace{
+		{Num: 1, Output: "eDP-1"},
+		{Num: 3, Output: "DP-1"},
+		{Num: 2, Output: "eDP-1"},
+		{Num: 4, Output: "DP-1"},
+	}
+	want := [][]i3.Workspace{
+		{{Num: 1, Output: "eDP-1"}, {Num: 2, Output: "eDP-1"}},
+		{{Num: 3, Output: "DP-1"}, {Num: 4, Output: "DP-1"}},
+	}
+	if got := groupByOutput(workspaces); !reflect.DeepEqual(got, want) {
+		t.Errorf("groupByOutput() = %+v, want %+v", got, want)
+	}
+	if got := groupByOutput(nil); got != nil {
+		t.Errorf("groupByOutput(nil) = %+v, want nil", got)
+	}
+}
+
+func TestNameWithoutNumberPrefix(t *testing.T) {
+	configDir := withConfigDir(t)
+	withNameFormat(t)
+	for name, icon := range map[string]string{"music": "♫", "★ stars": ""} {
+		dir := filepath.Join(configDir, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if icon == "" {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "icon"), []byte(icon), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range []struct {
+		name    string
+		want    string
+		renamed string // keepNumberPrefix of want, for workspace number 9
+	}{
+		{name: "1: ♫ music", want: "music", renamed: "9: ♫ music"},
+		// Glyphs are part of the name of workspaces without that icon.
+		{name: "2: – notes", want: "– notes", renamed: "9: – notes"},
+		{name: "3: ★ stars", want: "★ stars", renamed: "9: ★ stars"},
+	} {
+		ws := i3.Workspace{Name: tt.name}
+		if got := nameWithoutNumberPrefix(ws); got != tt.want {
+			t.Errorf("nameWithoutNumberPrefix(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		renamed := keepNumberPrefix(i3.Workspace{Num: 9}, tt.want)
+		if renamed != tt.renamed {
+			t.Errorf("keepNumberPrefix(%q) = %q, want %q", tt.want, renamed, tt.renamed)
+		}
+		if got := nameWithoutNumberPrefix(i3.Workspace{Name: renamed}); got != tt.want {
+			t.Errorf("nameWithoutNumberPrefix(%q) = %q, want %q", renamed, got, tt.want)
+		}
+	}
+}
@@ -1,27 +1,18 @@
-// The whole program uses log.Fatal for error handling, under the assumption
-// that any error is either a bug (likely with how we use GTK, or in gotk3), or
-// i3 having gone away, in which case this program should terminate, too.
-//
-// Workspaces are configured in ~/.config/wsmgr-for-i3/<name>. Each setting is
-// configured via its own file.
+//go:build cgo
+// +build cgo
+
 package main
 
 import (
-	"encoding/json"
 	"fmt"
+	"html"
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"strings"
-	"time"
 
-	"github.com/google/renameio/v2"
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
-	"github.com/spf13/cobra"
 	"go.i3wm.org/i3/v4"
 
 	_ "embed"
@@ -34,119 +25,152 @@ type wsmgr struct {
 		ignoreEvents bool
 	}
 
-	addWorkspaceButton *gtk.Button
+	addWorkspaceButton       *gtk.Button
+	configureWorkspaceButton *gtk.Button
+
+	workspaceLoaderTV    *gtk.TreeView
+	workspaceLoaderStore *gtk.ListStore
+	configButtons        *gtk.Box
+
+	win *gtk.Window
 
-	workspaceLoaderTV *gtk.TreeView
+	styleLabels map[string]*gtk.Label // see styleContext
 }
 
-func updateConfiguredWorkspaces(store *gtk.ListStore) {
-	configDir, err := os.UserConfigDir()
-	if err != nil {
-		log.Fatal(err)
+// Columns of the workspace loader ListStore.
+const (
+	loaderColumnName    = iota
+	loaderColumnIcon    // icon name, a warning icon for broken configs
+	loaderColumnSummary // see configInfo.Summary
+	loaderColumnLastLoad
+	loaderColumnTooltip // markup, see configInfo.Details
+	loaderColumnImage   // workspace icon image, see readIcon
+	loaderColumnGlyph   // markup, workspace icon glyph (see readIcon) in its color
+	loaderColumnStyle   // rowStyleColumns columns, see rowStyle
+)
+
+// Columns of the current workspace ListStore, following the workspace number
+// (0), name (1) and ID (2).
+const (
+	workspaceColumnOutput    = iota + 3
+	workspaceColumnMarkup    // name as markup, colored like in i3bar
+	workspaceColumnImage     // workspace icon image, see readIcon
+	workspaceColumnGlyph     // markup, unless the glyph is part of the name
+	workspaceColumnWorkspace // false for output header rows
+	workspaceColumnStyle     // rowStyleColumns columns, see rowStyle
+)
+
+// iconPixbuf loads the workspace icon image at path (see readIcon), returning
+// nil if path is empty or the image cannot be loaded.
+func iconPixbuf(path string) *gdk.Pixbuf {
+	if path == "" {
+		return nil
 	}
-	fis, err := ioutil.ReadDir(filepath.Join(configDir, "wsmgr-for-i3"))
+	pixbuf, err := gdk.PixbufNewFromFileAtScale(path, 16, 16, true)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return
-		}
-		log.Fatal(err)
+		log.Printf("%s: %v", path, err)
+		return nil
 	}
-	for _, fi := range fis {
-		if !fi.Mode().IsDir() {
-			continue
-		}
-		if fi.Name() == "." || fi.Name() == ".." {
-			continue
-		}
-		store.Set(store.Append(), []int{0, 1}, []interface{}{fi.Name(), 0})
+	return pixbuf
+}
+
+// glyphMarkup returns markup for the workspace icon glyph in color (if any).
+func glyphMarkup(glyph, color string) string {
+	if glyph == "" || color == "" {
+		return html.EscapeString(glyph)
 	}
+	return fmt.Sprintf("<span foreground='%s'>%s</span>", color, html.EscapeString(glyph))
 }
 
-func loadWorkspace(name string) error {
-	log.Printf("Loading workspace %q", name)
+// nameMarkup returns markup for the i3 workspace name name, which contains
+// markup if the workspace is colored (see wsname).
+func nameMarkup(name string) string {
+	if nameFormat.Parse(name).Color != "" {
+		return name
+	}
+	return html.EscapeString(name)
+}
 
-	configDir, err := os.UserConfigDir()
+func (w *wsmgr) updateConfiguredWorkspaces() {
+	names, err := configuredWorkspaces()
 	if err != nil {
-		return err
-	}
-	dir := filepath.Join(configDir, "wsmgr-for-i3", name)
-	cwd, err := filepath.EvalSymlinks(filepath.Join(dir, "cwd"))
-	if err != nil && !os.IsNotExist(err) {
-		return err
+		log.Fatal(err)
 	}
-	fis, err := ioutil.ReadDir(dir)
+	workspaces, err := i3.GetWorkspaces()
 	if err != nil {
-		return err
+		log.Fatal(err)
 	}
-	for _, fi := range fis {
-		if fi.Mode().IsDir() && (fi.Name() == "." || fi.Name() == "..") {
-			continue
-		}
-
-		if fi.Name() == "cwd" {
+	loaded := make(map[string]bool)
+	for _, ws := range workspaces {
+		loaded[nameWithoutNumberPrefix(ws)] = true
+	}
+	store := w.workspaceLoaderStore
+	store.Clear()
+	for _, name := range names {
+		info, err := inspectConfig(name)
+		if err != nil {
+			log.Print(err)
 			continue
 		}
-
-		path := filepath.Join(dir, fi.Name())
-
-		executable := fi.Mode()&0100 != 0
-		symlink := fi.Mode()&os.ModeSymlink != 0
-		if symlink {
-			fi, err := os.Stat(path)
-			if err != nil {
-				log.Print(err)
-				continue
-			}
-			executable = fi.Mode()&0100 != 0
-			if fi.Mode().IsDir() {
-				executable = false
-			}
-		}
-		if executable {
-			log.Printf("starting executable %s", path)
-			// File executable by its owner, try to execute it
-			cmd := exec.Command(path)
-			if cwd != "" {
-				cmd.Dir = cwd
-			}
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			go func() {
-				if err := cmd.Run(); err != nil {
-					log.Printf("%v: %v", cmd.Args, err)
-				}
-			}()
-		}
-
-		if fi.Name() == "chrome-rewindow" {
-			b, err := ioutil.ReadFile(path)
-			if err != nil {
-				log.Print(err)
-				continue
-			}
-			n := strings.TrimSpace(string(b))
-			cmd := exec.Command("wsmgr-chrome-rewindow", "-name="+n)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			go func() {
-				if err := cmd.Run(); err != nil {
-					log.Printf("%v: %v", cmd.Args, err)
-				}
-			}()
+		icon := ""
+		if len(info.Warnings) > 0 {
+			icon = "dialog-warning"
+		}
+		state := "unloaded"
+		if loaded[name] {
+			state = "loaded"
+		}
+		iter := store.Append()
+		store.Set(iter, []int{
+			loaderColumnName,
+			loaderColumnIcon,
+			loaderColumnSummary,
+			loaderColumnLastLoad,
+			loaderColumnTooltip,
+			loaderColumnGlyph,
+			loaderColumnStyle,
+			loaderColumnStyle + 1,
+			loaderColumnStyle + 2,
+			loaderColumnStyle + 3,
+			loaderColumnStyle + 4,
+			loaderColumnStyle + 5,
+		}, append([]interface{}{
+			name,
+			icon,
+			info.Summary(),
+			info.LastLoadString(),
+			html.EscapeString(info.Details()),
+			glyphMarkup(info.Icon, info.Color),
+		}, w.rowStyle(state)...))
+		if pixbuf := iconPixbuf(info.IconImage); pixbuf != nil {
+			store.SetValue(iter, loaderColumnImage, pixbuf)
 		}
 	}
-	return nil
 }
 
-func (w *wsmgr) initWorkspaceLoaderTV() {
-	tv, err := gtk.TreeViewNew()
+// packWorkspaceIcon adds renderers for the workspace icon (an image or a
+// glyph, see readIcon) to tvc.
+func (w *wsmgr) packWorkspaceIcon(tvc *gtk.TreeViewColumn, imageColumn, glyphColumn int) {
+	image, err := gtk.CellRendererPixbufNew()
 	if err != nil {
 		log.Fatal(err)
 	}
-	workspaceNameRenderer, err := gtk.CellRendererTextNew()
+	tvc.PackStart(image, false)
+	tvc.AddAttribute(image, "pixbuf", imageColumn)
+	glyph, err := gtk.CellRendererTextNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tvc.PackStart(glyph, false)
+	tvc.AddAttribute(glyph, "markup", glyphColumn)
+}
+
+func (w *wsmgr) initWorkspaceLoaderTV() {
+	tv, err := gtk.TreeViewNew()
 	if err != nil {
 		log.Fatal(err)
 	}
+	tv.SetName("loader")
 
 	{
 		tvc, err := gtk.TreeViewColumnNew()
@@ -154,18 +178,68 @@ func (w *wsmgr) initWorkspaceLoaderTV() {
 			log.Fatal(err)
 		}
 		tvc.SetTitle("load workspace…")
-		renderer := workspaceNameRenderer // for convenience
+		icon, err := gtk.CellRendererPixbufNew()
+		if err != nil {
+			log.Fatal(err)
+		}
+		tvc.PackStart(icon, false)
+		tvc.AddAttribute(icon, "icon-name", loaderColumnIcon)
+		w.packWorkspaceIcon(tvc, loaderColumnImage, loaderColumnGlyph)
+		renderer, err := gtk.CellRendererTextNew()
+		if err != nil {
+			log.Fatal(err)
+		}
 		tvc.PackStart(renderer, true)
-		tvc.AddAttribute(renderer, "text", 0 /* references column 0 in model */)
+		tvc.AddAttribute(renderer, "text", loaderColumnName)
+		addStyleAttributes(tvc, renderer, loaderColumnStyle)
 		tv.AppendColumn(tvc)
 	}
 
-	store, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_INT64)
+	for _, col := range []struct {
+		title  string
+		column int
+	}{
+		{"configuration", loaderColumnSummary},
+		{"last loaded", loaderColumnLastLoad},
+	} {
+		tvc, err := gtk.TreeViewColumnNew()
+		if err != nil {
+			log.Fatal(err)
+		}
+		tvc.SetTitle(col.title)
+		renderer, err := gtk.CellRendererTextNew()
+		if err != nil {
+			log.Fatal(err)
+		}
+		tvc.PackStart(renderer, true)
+		tvc.AddAttribute(renderer, "text", col.column)
+		addStyleAttributes(tvc, renderer, loaderColumnStyle)
+		tv.AppendColumn(tvc)
+	}
+
+	store, err := gtk.ListStoreNew(
+		glib.TYPE_STRING,    // loaderColumnName
+		glib.TYPE_STRING,    // loaderColumnIcon
+		glib.TYPE_STRING,    // loaderColumnSummary
+		glib.TYPE_STRING,    // loaderColumnLastLoad
+		glib.TYPE_STRING,    // loaderColumnTooltip
+		gdk.PixbufGetType(), // loaderColumnImage
+		glib.TYPE_STRING,    // loaderColumnGlyph
+		glib.TYPE_STRING,    // loaderColumnStyle: foreground
+		glib.TYPE_BOOLEAN,
+		glib.TYPE_STRING, // background
+		glib.TYPE_BOOLEAN,
+		glib.TYPE_INT, // weight
+		glib.TYPE_BOOLEAN)
 	if err != nil {
 		log.Fatal(err)
 	}
-	updateConfiguredWorkspaces(store)
+	w.workspaceLoaderStore = store
+	w.updateConfiguredWorkspaces()
 	tv.SetModel(store)
+	tv.SetTooltipColumn(loaderColumnTooltip)
+	tv.SetEnableSearch(true)
+	tv.SetSearchColumn(loaderColumnName)
 
 	tv.Connect("row-activated", func(tv *gtk.TreeView, path *gtk.TreePath, column *gtk.TreeViewColumn) {
 		iter, err := store.GetIter(path)
@@ -173,9 +247,9 @@ func (w *wsmgr) initWorkspaceLoaderTV() {
 			log.Fatalf("BUG: GetIterFromString(%q) = %v", path, err)
 		}
 
-		nameval, err := store.GetValue(iter, 0)
+		nameval, err := store.GetValue(iter, loaderColumnName)
 		if err != nil {
-			log.Fatalf("BUG: GetValue(0) = %v", err)
+			log.Fatalf("BUG: GetValue(%d) = %v", loaderColumnName, err)
 		}
 		name, err := nameval.GetString()
 		if err != nil {
@@ -186,6 +260,7 @@ func (w *wsmgr) initWorkspaceLoaderTV() {
 		if err := loadWorkspace(name); err != nil {
 			log.Fatal(err)
 		}
+		w.updateConfiguredWorkspaces() // last load time
 	})
 
 	w.workspaceLoaderTV = tv
@@ -199,12 +274,99 @@ func (w *wsmgr) updateWorkspaces() {
 	}
 	store := w.currentWorkspace.store
 	store.Clear()
-	for _, ws := range workspaces {
-		store.Set(store.Append(), []int{0, 1, 2}, []interface{}{ws.Num, ws.Name, ws.ID})
+	groups := groupByOutput(workspaces)
+	for _, group := range groups {
+		if len(groups) > 1 {
+			iter := store.Append()
+			store.Set(iter, []int{
+				0,
+				1,
+				2,
+				workspaceColumnOutput,
+				workspaceColumnMarkup,
+				workspaceColumnWorkspace,
+				workspaceColumnStyle,
+				workspaceColumnStyle + 1,
+				workspaceColumnStyle + 2,
+				workspaceColumnStyle + 3,
+				workspaceColumnStyle + 4,
+				workspaceColumnStyle + 5,
+			}, append([]interface{}{
+				int64(0),
+				"",
+				int64(0),
+				group[0].Output,
+				html.EscapeString(group[0].Output),
+				false,
+			}, w.rowStyle("output")...))
+		}
+		for _, ws := range group {
+			w.appendWorkspace(ws)
+		}
 	}
 	w.currentWorkspace.ignoreEvents = false
 }
 
+// appendWorkspace appends a row for the open workspace ws.
+func (w *wsmgr) appendWorkspace(ws i3.Workspace) {
+	var state string
+	switch {
+	case ws.Urgent:
+		state = "urgent"
+	case ws.Focused:
+		state = "focused"
+	case ws.Visible:
+		state = "visible"
+	}
+	name := nameWithoutNumberPrefix(ws)
+	glyph, image := workspaceIcon(name)
+	if nameFormat.Parse(ws.Name).Icon != "" {
+		glyph = "" // already part of the name
+	}
+	store := w.currentWorkspace.store
+	iter := store.Append()
+	store.Set(iter, []int{
+		0,
+		1,
+		2,
+		workspaceColumnOutput,
+		workspaceColumnMarkup,
+		workspaceColumnGlyph,
+		workspaceColumnWorkspace,
+		workspaceColumnStyle,
+		workspaceColumnStyle + 1,
+		workspaceColumnStyle + 2,
+		workspaceColumnStyle + 3,
+		workspaceColumnStyle + 4,
+		workspaceColumnStyle + 5,
+	}, append([]interface{}{
+		ws.Num,
+		ws.Name,
+		ws.ID,
+		ws.Output,
+		nameMarkup(ws.Name),
+		glyphMarkup(glyph, workspaceColor(name)),
+		true,
+	}, w.rowStyle(state)...))
+	if pixbuf := iconPixbuf(image); pixbuf != nil {
+		store.SetValue(iter, workspaceColumnImage, pixbuf)
+	}
+}
+
+// isHeader returns whether iter points to an output header row (shown when
+// there are multiple outputs) instead of a workspace.
+func (w *wsmgr) isHeader(iter *gtk.TreeIter) bool {
+	val, err := w.currentWorkspace.store.GetValue(iter, workspaceColumnWorkspace)
+	if err != nil {
+		log.Fatalf("BUG: GetValue(workspaceColumnWorkspace) = %v", err)
+	}
+	workspace, err := val.GoValue()
+	if err != nil {
+		log.Fatalf("BUG: GoValue() = %v", err)
+	}
+	return !workspace.(bool)
+}
+
 func (w *wsmgr) workspaceFromIter(iter *gtk.TreeIter) i3.Workspace {
 	store := w.currentWorkspace.store
 
@@ -234,10 +396,19 @@ func (w *wsmgr) workspaceFromIter(iter *gtk.TreeIter) i3.Workspace {
 	if err != nil {
 		log.Fatalf("BUG: GoValue() = %v", err)
 	}
+	outputval, err := store.GetValue(iter, workspaceColumnOutput)
+	if err != nil {
+		log.Fatalf("BUG: GetValue(workspaceColumnOutput) = %v", err)
+	}
+	output, err := outputval.GetString()
+	if err != nil {
+		log.Fatalf("BUG: GetString() = %v", err)
+	}
 	return i3.Workspace{
-		ID:   i3.WorkspaceID(id.(int64)),
-		Num:  num.(int64),
-		Name: name,
+		ID:     i3.WorkspaceID(id.(int64)),
+		Num:    num.(int64),
+		Name:   name,
+		Output: output,
 	}
 }
 
@@ -254,6 +425,7 @@ func (w *wsmgr) initCurrentWorkspaceTV() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	tv.SetName("workspaces")
 	workspaceNameRenderer, err := gtk.CellRendererTextNew()
 	if err != nil {
 		log.Fatal(err)
@@ -271,6 +443,9 @@ func (w *wsmgr) initCurrentWorkspaceTV() {
 		}
 		tvc.PackStart(renderer, true)
 		tvc.AddAttribute(renderer, "text", 0 /* references column 0 in model */)
+		addStyleAttributes(tvc, renderer, workspaceColumnStyle)
+		// Output header rows have no number.
+		tvc.AddAttribute(renderer, "visible", workspaceColumnWorkspace)
 		tv.AppendColumn(tvc)
 	}
 
@@ -282,15 +457,31 @@ func (w *wsmgr) initCurrentWorkspaceTV() {
 		}
 		titleColumn = tvc
 		tvc.SetTitle("name")
+		w.packWorkspaceIcon(tvc, workspaceColumnImage, workspaceColumnGlyph)
 		renderer := workspaceNameRenderer // for convenience
 		tvc.PackStart(renderer, true)
-		tvc.AddAttribute(renderer, "text", 1 /* references column 0 in model */)
+		tvc.AddAttribute(renderer, "markup", workspaceColumnMarkup)
+		addStyleAttributes(tvc, renderer, workspaceColumnStyle)
 		tv.AppendColumn(tvc)
 	}
 
 	// TODO: could we implement a custom model? https://github.com/gotk3/gotk3/issues/721
 	// Maybe that would free us from doing the awkward putting/getting into a gtk.ListStore
-	store, err := gtk.ListStoreNew(glib.TYPE_INT64, glib.TYPE_STRING, glib.TYPE_INT64)
+	store, err := gtk.ListStoreNew(
+		glib.TYPE_INT64,
+		glib.TYPE_STRING,
+		glib.TYPE_INT64,
+		glib.TYPE_STRING,    // workspaceColumnOutput
+		glib.TYPE_STRING,    // workspaceColumnMarkup
+		gdk.PixbufGetType(), // workspaceColumnImage
+		glib.TYPE_STRING,    // workspaceColumnGlyph
+		glib.TYPE_BOOLEAN,   // workspaceColumnWorkspace
+		glib.TYPE_STRING,    // workspaceColumnStyle: foreground
+		glib.TYPE_BOOLEAN,
+		glib.TYPE_STRING, // background
+		glib.TYPE_BOOLEAN,
+		glib.TYPE_INT, // weight
+		glib.TYPE_BOOLEAN)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -313,7 +504,7 @@ func (w *wsmgr) initCurrentWorkspaceTV() {
 		}
 		for {
 			ws := w.workspaceFromIter(iter)
-			if ws.Num == focused {
+			if ws.Num == focused && !w.isHeader(iter) {
 				path, err := store.GetPath(iter)
 				if err != nil {
 					log.Printf("GetPath(%v): %v", iter, err)
@@ -328,7 +519,8 @@ func (w *wsmgr) initCurrentWorkspaceTV() {
 		}
 	}
 
-	workspaceNameRenderer.SetProperty("editable", true)
+	// Output header rows cannot be renamed.
+	titleColumn.AddAttribute(workspaceNameRenderer, "editable", workspaceColumnWorkspace)
 	workspaceNameRenderer.Connect("edited", func(cell *gtk.CellRendererText, path string, newText string) {
 		iter, err := store.GetIterFromString(path)
 		if err != nil {
@@ -336,10 +528,7 @@ func (w *wsmgr) initCurrentWorkspaceTV() {
 		}
 
 		existing := w.workspaceFromPath(path)
-		numPrefix := fmt.Sprintf("%d: ", existing.Num)
-		if !strings.HasPrefix(newText, numPrefix) {
-			newText = numPrefix + newText
-		}
+		newText = keepNumberPrefix(existing, newText)
 
 		cmd := fmt.Sprintf(`rename workspace "%s" to "%s"`, existing.Name, newText)
 		log.Printf("renaming workspace: %q", cmd)
@@ -347,9 +536,7 @@ func (w *wsmgr) initCurrentWorkspaceTV() {
 			log.Print(err)
 			return
 		}
-		store.Set(iter, []int{0, 1, 2}, []interface{}{
-			existing.Num, newText, existing.ID,
-		})
+		store.Set(iter, []int{1, workspaceColumnMarkup}, []interface{}{newText, nameMarkup(newText)})
 	})
 
 	tv.SetReorderable(true)
@@ -381,28 +568,16 @@ func (w *wsmgr) initCurrentWorkspaceTV() {
 
 		log.Printf("row-deleted, path %v", path)
 
-		var num int64
+		// Dragging a workspace only changes its position, not its output.
+		var order []i3.Workspace
 		for iter, ok := store.GetIterFirst(); ok; ok = store.IterNext(iter) {
-			num++
-			ws := w.workspaceFromIter(iter)
-			log.Printf("  ws = %+v", ws)
-			if ws.Num == num {
-				continue // no rename required
-			}
-			oldName := ws.Name
-			numPrefix := fmt.Sprintf("%d: ", ws.Num)
-			if strings.Contains(ws.Name, ":") {
-				// Named workspace
-				ws.Name = fmt.Sprintf("%d: %s", num, strings.TrimPrefix(ws.Name, numPrefix))
-			} else {
-				// Numbered workspace
-				ws.Name = fmt.Sprintf("%d", num)
-			}
-			rename := fmt.Sprintf(`rename workspace "%s" to "%s"`, oldName, ws.Name)
-			log.Printf("  -> rename=%q", rename)
-			if _, err := i3.RunCommand(rename); err != nil {
-				log.Fatal(err)
+			if w.isHeader(iter) {
+				continue
 			}
+			order = append(order, w.workspaceFromIter(iter))
+		}
+		if err := renumber(order); err != nil {
+			log.Fatal(err)
 		}
 
 		w.updateWorkspaces()
@@ -413,7 +588,14 @@ func (w *wsmgr) initCurrentWorkspaceTV() {
 	// which windows are present on which workspace, without having to deal with
 	// moving windows around manually.
 	tv.Connect("row-activated", func(tv *gtk.TreeView, path *gtk.TreePath, column *gtk.TreeViewColumn) {
-		activated := w.workspaceFromPath(path.String())
+		iter, err := store.GetIter(path)
+		if err != nil {
+			log.Fatalf("BUG: GetIter(%v) = %v", path, err)
+		}
+		if w.isHeader(iter) {
+			return
+		}
+		activated := w.workspaceFromIter(iter)
 		log.Printf("row-activated signal for workspace %+v", activated)
 		cmd := fmt.Sprintf(`move container to workspace "%s"; workspace "%s"`, activated.Name, activated.Name)
 		if _, err := i3.RunCommand(cmd); err != nil {
@@ -425,15 +607,13 @@ func (w *wsmgr) initCurrentWorkspaceTV() {
 }
 
 func (w *wsmgr) addWorkspace(name string) {
-	store := w.currentWorkspace.store
-	var highest int64
-	for iter, ok := store.GetIterFirst(); ok; ok = store.IterNext(iter) {
-		ws := w.workspaceFromIter(iter)
-		if ws.Num > highest {
-			highest = ws.Num
-		}
+	// The numbering policy needs to know the focused workspace, which the
+	// store does not contain.
+	workspaces, err := i3.GetWorkspaces()
+	if err != nil {
+		log.Fatal(err)
 	}
-	newName := fmt.Sprintf("%d: %s", highest+1, name)
+	newName := nextWorkspaceName(workspaces, name)
 
 	cmd := fmt.Sprintf(`move container to workspace "%s"; workspace "%s"`, newName, newName)
 	if _, err := i3.RunCommand(cmd); err != nil {
@@ -443,11 +623,91 @@ func (w *wsmgr) addWorkspace(name string) {
 	w.updateWorkspaces()
 }
 
+// handleControl shows, raises or hides the window as requested by another
+// wsmgr invocation (see control.go).
+func (w *wsmgr) handleControl(msg controlMessage) {
+	if msg.toggle() && w.win.IsVisible() && w.win.IsActive() {
+		w.win.Hide()
+		return
+	}
+	w.updateWorkspaces()
+	w.updateConfiguredWorkspaces()
+	if w.win.IsVisible() {
+		// Hide the window first so that i3 maps it on the current
+		// workspace, not on the workspace it was previously shown on.
+		w.win.Hide()
+	}
+	w.win.ShowAll()
+	w.win.Present()
+	w.applyMode(msg)
+}
+
+// applyMode selects a workspace and/or focuses the search, as specified via
+// --select and --focus-search.
+func (w *wsmgr) applyMode(msg controlMessage) {
+	if msg.Select != "" {
+		w.selectWorkspace(msg.Select)
+	}
+	if msg.FocusSearch {
+		tv := w.workspaceLoaderTV
+		tv.GrabFocus()
+		if _, err := tv.Emit("start-interactive-search"); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// selectWorkspace moves the cursor to the open workspace name (with or
+// without number prefix), or to the configured workspace name.
+func (w *wsmgr) selectWorkspace(name string) {
+	store := w.currentWorkspace.store
+	for iter, ok := store.GetIterFirst(); ok; ok = store.IterNext(iter) {
+		if w.isHeader(iter) {
+			continue
+		}
+		ws := w.workspaceFromIter(iter)
+		if ws.Name != name && nameWithoutNumberPrefix(ws) != name {
+			continue
+		}
+		path, err := store.GetPath(iter)
+		if err != nil {
+			log.Fatalf("BUG: GetPath() = %v", err)
+		}
+		w.currentWorkspace.tv.SetCursor(path, nil, false /* startEditing */)
+		w.currentWorkspace.tv.GrabFocus()
+		return
+	}
+
+	loader := w.workspaceLoaderStore
+	for iter, ok := loader.GetIterFirst(); ok; ok = loader.IterNext(iter) {
+		nameval, err := loader.GetValue(iter, loaderColumnName)
+		if err != nil {
+			log.Fatalf("BUG: GetValue(%d) = %v", loaderColumnName, err)
+		}
+		configured, err := nameval.GetString()
+		if err != nil {
+			log.Fatalf("BUG: GetString() = %v", err)
+		}
+		if configured != name {
+			continue
+		}
+		path, err := loader.GetPath(iter)
+		if err != nil {
+			log.Fatalf("BUG: GetPath() = %v", err)
+		}
+		w.workspaceLoaderTV.SetCursor(path, nil, false /* startEditing */)
+		w.workspaceLoaderTV.GrabFocus()
+		return
+	}
+	log.Printf("no open or configured workspace %q found", name)
+}
+
 func (w *wsmgr) initAddWorkspaceButton() {
 	addButton, err := gtk.ButtonNewWithMnemonic("_add workspace")
 	if err != nil {
 		log.Fatal(err)
 	}
+	addButton.SetName("add-workspace")
 	addButton.Connect("clicked", func() {
 		log.Printf("adding new workspace")
 		w.addWorkspace("unnamed")
@@ -455,6 +715,69 @@ func (w *wsmgr) initAddWorkspaceButton() {
 	w.addWorkspaceButton = addButton
 }
 
+// initConfigButtons creates the buttons which open the configuration editor
+// for the selected open workspace, for the selected configured workspace, or
+// for a new workspace configuration.
+func (w *wsmgr) initConfigButtons() {
+	configureButton, err := gtk.ButtonNewWithMnemonic("_configure workspace…")
+	if err != nil {
+		log.Fatal(err)
+	}
+	configureButton.SetName("configure-workspace")
+	configureButton.Connect("clicked", func() {
+		sel, err := w.currentWorkspace.tv.GetSelection()
+		if err != nil {
+			log.Fatal(err)
+		}
+		_, iter, ok := sel.GetSelected()
+		if !ok || w.isHeader(iter) {
+			return
+		}
+		ws := w.workspaceFromIter(iter)
+		w.editConfig(nameWithoutNumberPrefix(ws))
+	})
+	w.configureWorkspaceButton = configureButton
+
+	hbox, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hbox.SetName("config-buttons")
+	newButton, err := gtk.ButtonNewWithMnemonic("_new configuration…")
+	if err != nil {
+		log.Fatal(err)
+	}
+	newButton.Connect("clicked", func() {
+		w.editConfig("")
+	})
+	editButton, err := gtk.ButtonNewWithMnemonic("_edit configuration…")
+	if err != nil {
+		log.Fatal(err)
+	}
+	editButton.Connect("clicked", func() {
+		sel, err := w.workspaceLoaderTV.GetSelection()
+		if err != nil {
+			log.Fatal(err)
+		}
+		_, iter, ok := sel.GetSelected()
+		if !ok {
+			return
+		}
+		nameval, err := w.workspaceLoaderStore.GetValue(iter, loaderColumnName)
+		if err != nil {
+			log.Fatalf("BUG: GetValue(%d) = %v", loaderColumnName, err)
+		}
+		name, err := nameval.GetString()
+		if err != nil {
+			log.Fatalf("BUG: GetString() = %v", err)
+		}
+		w.editConfig(name)
+	})
+	hbox.PackStart(newButton, false, false, 0)
+	hbox.PackStart(editButton, false, false, 0)
+	w.configButtons = hbox
+}
+
 //go:embed "logo.png"
 var logoPNG []byte
 
@@ -482,212 +805,105 @@ func setIconFromEmbeddedResource(b []byte, win *gtk.Window) error {
 	return nil
 }
 
-func autosave() error {
-	workspaces, err := i3.GetWorkspaces()
-	if err != nil {
-		log.Fatal(err)
+// gui shows the wsmgr window, or (if wsmgr is already running) sends the
+// running instance a control message.
+func gui() error {
+	msg := controlMessage{
+		FocusSearch: focusSearch,
+		Select:      selectName,
 	}
-	configDir, err := os.UserConfigDir()
+	sock, err := controlSocketPath()
 	if err != nil {
 		return err
 	}
-	autosaveFile := filepath.Join(configDir, "wsmgr-for-i3", "autosave.json")
-	f, err := renameio.TempFile("", autosaveFile)
+	// Claim the control socket before the (slow) GTK initialization, so that
+	// further invocations reach this instance instead of starting another one.
+	ln, err := claimControl(sock, msg)
 	if err != nil {
 		return err
 	}
-	defer f.Cleanup()
-	b, err := json.Marshal(workspaces)
-	if err != nil {
-		return err
+	if ln == nil {
+		return nil // the running instance handles msg
 	}
-	f.Write(b)
-	return f.CloseAtomicallyReplace()
-}
+	defer ln.Close()
+	var w *wsmgr
+	// Messages received during initialization are handled once the GTK main
+	// loop runs, i.e. after w was set up.
+	serveControl(ln, func(msg controlMessage) {
+		glib.IdleAdd(func() {
+			w.handleControl(msg)
+		})
+	})
 
-func nameWithoutNumberPrefix(ws i3.Workspace) string {
-	numPrefix := fmt.Sprintf("%d: ", ws.Num)
-	if strings.Contains(ws.Name, ":") {
-		// Named workspace
-		return strings.TrimPrefix(ws.Name, numPrefix)
-	}
-	// Numbered workspace
-	return ws.Name
-}
+	// Initialize GTK without parsing any command line arguments.
+	gtk.Init(nil)
 
-func makeItSo(dryRun bool, current, desired []i3.Workspace) error {
-	currentByName := make(map[string]i3.Workspace)
-	for _, ws := range current {
-		currentByName[nameWithoutNumberPrefix(ws)] = ws
+	// Create a new toplevel window, set its title, and connect it to the
+	// "destroy" signal to exit the GTK main loop when it is destroyed.
+	win, err := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	if err != nil {
+		return err
 	}
+	win.SetName("win")
+	win.SetModal(true)
 
-	for _, ws := range desired {
-		if !strings.Contains(ws.Name, ":") {
-			continue
-		}
-		name := nameWithoutNumberPrefix(ws)
-		current, ok := currentByName[name]
-		if !ok {
-			if dryRun {
-				log.Printf("dry-run: load workspace %q to %q", name, ws.Name)
-				continue
-			}
-
-			cmd := fmt.Sprintf(`workspace "%s"`, ws.Name)
-			if _, err := i3.RunCommand(cmd); err != nil {
-				log.Fatal(err)
-			}
-			if err := loadWorkspace(name); err != nil {
-				log.Printf("loading workspace %q failed: %v", name, err)
-			}
-			time.Sleep(1 * time.Second) // TODO: remove this sleep if nothing breaks
-			continue
-		}
-
-		if current.Num != ws.Num {
-			// Workspace exists, but has the wrong number, rename it.
-			cmd := fmt.Sprintf(`rename workspace "%s" to "%s"`, current.Name, ws.Name)
-			if dryRun {
-				log.Printf("dry-run: %s", cmd)
-				continue
-			}
+	win.SetTitle("wsmgr-for-i3 workspace manager")
+	win.Connect("destroy", func() {
+		gtk.MainQuit()
+	})
 
-			if _, err := i3.RunCommand(cmd); err != nil {
-				return err
-			}
+	if err := setIconFromEmbeddedResource(logoPNG, win); err != nil {
+		log.Print(err)
+	}
 
-			continue
+	w = &wsmgr{win: win}
+	// Load the style before creating the lists, which look up their
+	// row styles in the style.
+	if err := initStyle(theme, func() {
+		if w.currentWorkspace.store == nil {
+			return // called before the lists were created
 		}
+		w.updateWorkspaces()
+		w.updateConfiguredWorkspaces()
+	}); err != nil {
+		return err
 	}
-	return nil
-}
+	w.initCurrentWorkspaceTV()
+	w.initAddWorkspaceButton()
+	w.initWorkspaceLoaderTV()
+	w.initConfigButtons()
 
-func restore(dryRun bool) error {
-	currentWorkspaces, err := i3.GetWorkspaces()
-	if err != nil {
-		log.Fatal(err)
-	}
-	configDir, err := os.UserConfigDir()
+	vbox, err := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 10)
 	if err != nil {
 		return err
 	}
-	autosaveFile := filepath.Join(configDir, "wsmgr-for-i3", "autosave.json")
-	b, err := ioutil.ReadFile(autosaveFile)
+	vbox.PackStart(w.currentWorkspace.tv, true, true, 5)
+	buttons, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 5)
 	if err != nil {
 		return err
 	}
-	var desiredWorkspaces []i3.Workspace
-	if err := json.Unmarshal(b, &desiredWorkspaces); err != nil {
-		return err
-	}
-
-	return makeItSo(dryRun, currentWorkspaces, desiredWorkspaces)
-}
-
-var rootCmd = &cobra.Command{
-	Use:   "wsmgr",
-	Short: "workspace manager",
-	Long:  "workspace manager",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		// Initialize GTK without parsing any command line arguments.
-		gtk.Init(nil)
-
-		cssProvider, err := gtk.CssProviderNew()
-		if err != nil {
-			return err
-		}
-
-		// TODO: can we use box-shadow?
-		// https://developer.gnome.org/gtk3/stable/chap-css-overview.html
-		// https://developer.gnome.org/gtk3/stable/chap-css-properties.html
-		cssProvider.LoadFromData(css)
-
-		defaultScreen, err := gdk.ScreenGetDefault()
-		if err != nil {
-			return err
-		}
-		gtk.AddProviderForScreen(defaultScreen, cssProvider, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
-
-		// Create a new toplevel window, set its title, and connect it to the
-		// "destroy" signal to exit the GTK main loop when it is destroyed.
-		win, err := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
-		if err != nil {
-			return err
-		}
-		win.SetName("win")
-		win.SetModal(true)
+	buttons.PackStart(w.addWorkspaceButton, true, true, 0)
+	buttons.PackStart(w.configureWorkspaceButton, false, false, 0)
+	vbox.PackStart(buttons, false, false, 5)
+	vbox.PackStart(w.workspaceLoaderTV, false, false, 5)
+	vbox.PackStart(w.configButtons, false, false, 5)
+	win.Add(vbox)
 
-		win.SetTitle("wsmgr-for-i3 workspace manager")
-		win.Connect("destroy", func() {
-			gtk.MainQuit()
-		})
-
-		if err := setIconFromEmbeddedResource(logoPNG, win); err != nil {
-			log.Print(err)
-		}
-
-		w := &wsmgr{}
-		w.initCurrentWorkspaceTV()
-		w.initAddWorkspaceButton()
-		w.initWorkspaceLoaderTV()
-
-		vbox, err := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 10)
-		if err != nil {
-			return err
-		}
-		vbox.PackStart(w.currentWorkspace.tv, true, true, 5)
-		vbox.PackStart(w.addWorkspaceButton, false, false, 5)
-		vbox.PackStart(w.workspaceLoaderTV, false, false, 5)
-		win.Add(vbox)
+	// Set the default window size.
+	win.SetDefaultSize(800, 600)
 
-		// Set the default window size.
-		win.SetDefaultSize(800, 600)
+	// Recursively show all widgets contained in this window.
+	win.ShowAll()
+	w.applyMode(msg)
 
-		// Recursively show all widgets contained in this window.
-		win.ShowAll()
+	// Begin executing the GTK main loop.  This blocks until
+	// gtk.MainQuit() is run.
+	gtk.Main()
+	ln.Close()
 
-		// Begin executing the GTK main loop.  This blocks until
-		// gtk.MainQuit() is run.
-		gtk.Main()
-
-		return nil
-	},
-}
-
-var autosaveCmd = &cobra.Command{
-	Use:   "autosave",
-	Short: "save workspace names to the autosave file",
-	Long:  "do not show the GUI, instead save workspace names to the autosave file",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		return autosave()
-	},
-}
-
-var restoreCmd = &cobra.Command{
-	Use:   "restore",
-	Short: "restore workspace names from the autosave file",
-	Long:  "do not show the GUI, instead restore workspace names from the autosave file",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		return restore(dryRun)
-	},
-}
-
-var dryRun bool
-
-func ws() error {
-	restoreCmd.Flags().BoolVarP(&dryRun, "dry-run", "", false, "do not change anything (dry-run mode)")
-	rootCmd.AddCommand(autosaveCmd)
-	rootCmd.AddCommand(restoreCmd)
-
-	if err := rootCmd.Execute(); err != nil {
-		return err
-	}
+	// Keep running (without a window) until all windows of loaded
+	// workspaces were placed.
+	trackers.Wait()
 
 	return nil
 }
-
-func main() {
-	if err := ws(); err != nil {
-		log.Fatal(err)
-	}
-}
@@ -3,6 +3,7 @@ module github.com/stapelberg/wsmgr-for-i3
 go 1.16
 
 require (
+	github.com/BurntSushi/xgb v0.0.0-20160522181843-27f122750802
 	github.com/google/renameio/v2 v2.0.0
 	github.com/gotk3/gotk3 v0.6.0
 	github.com/spf13/cobra v1.4.0 // indirect
@@ -0,0 +1,323 @@
+// Package cdp is a minimal client for the Chrome DevTools Protocol, which
+// Chromium-based browsers offer when started with --remote-debugging-port.
+//
+// Only what is required to call methods is implemented: the HTTP discovery
+// endpoint and a WebSocket client (RFC 6455) for text messages.
+//
+// See https://chromedevtools.github.io/devtools-protocol/ for the protocol.
+package cdp
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// timeout bounds each network operation (discovery request, handshake, method
+// call), so that a wedged browser does not block forever.
+var timeout = 5 * time.Second
+
+// Version is the response of the /json/version discovery endpoint.
+type Version struct {
+	Browser              string `json:"Browser"`
+	WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+}
+
+// GetVersion queries the discovery endpoint of the browser listening on
+// localhost:port.
+func GetVersion(port int) (Version, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/json/version", port))
+	if err != nil {
+		return Version{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Version{}, fmt.Errorf("%s: unexpected HTTP status %v", resp.Request.URL, resp.Status)
+	}
+	var v Version
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return Version{}, err
+	}
+	return v, nil
+}
+
+// Conn is a DevTools protocol connection.
+type Conn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	nextID int64
+}
+
+// Dial opens a DevTools protocol connection to the specified WebSocket URL,
+// e.g. Version.WebSocketDebuggerURL.
+func Dial(wsURL string) (*Conn, error) {
+	u, err := url.Parse(wsURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ws" {
+		return nil, fmt.Errorf("unsupported WebSocket URL scheme %q", u.Scheme)
+	}
+	conn, err := net.DialTimeout("tcp", u.Host, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var nonce [16]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req := "GET " + u.RequestURI() + " HTTP/1.1\r\n" +
+		"Host: " + u.Host + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n" +
+		"\r\n"
+	if _, err := io.WriteString(conn, req); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, &http.Request{Method: "GET"})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("WebSocket handshake: unexpected HTTP status %v", resp.Status)
+	}
+	h := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	if got, want := resp.Header.Get("Sec-WebSocket-Accept"), base64.StdEncoding.EncodeToString(h[:]); got != want {
+		conn.Close()
+		return nil, fmt.Errorf("WebSocket handshake: invalid Sec-WebSocket-Accept %q", got)
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, r: r}, nil
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Error is an error returned by the browser.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("DevTools error %d: %s", e.Code, e.Message)
+}
+
+// Call calls the specified method and unmarshals its result into result
+// (unless nil). Events which arrive in the meantime are discarded.
+func (c *Conn) Call(method string, params, result interface{}) error {
+	if err := c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	defer c.conn.SetDeadline(time.Time{})
+	c.nextID++
+	id := c.nextID
+	msg := struct {
+		ID     int64       `json:"id"`
+		Method string      `json:"method"`
+		Params interface{} `json:"params,omitempty"`
+	}{id, method, params}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := c.writeFrame(opText, b); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	for {
+		b, err := c.readMessage()
+		if err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+		var resp struct {
+			ID     int64           `json:"id"`
+			Result json.RawMessage `json:"result"`
+			Error  *Error          `json:"error"`
+		}
+		if err := json.Unmarshal(b, &resp); err != nil {
+			return err
+		}
+		if resp.ID != id {
+			continue // event, or response to a different call
+		}
+		if resp.Error != nil {
+			return fmt.Errorf("%s: %w", method, resp.Error)
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	}
+}
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// writeFrame writes a single (unfragmented) frame. Frames sent by clients must
+// be masked.
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	hdr := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = append(hdr, 0x80|byte(n))
+	case n <= 0xffff:
+		hdr = append(hdr, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(hdr[2:], uint16(n))
+	default:
+		hdr = append(hdr, 0x80|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(hdr[2:], uint64(n))
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(rand.Reader, mask[:]); err != nil {
+		return err
+	}
+	hdr = append(hdr, mask[:]...)
+	masked := make([]byte, len(payload))
+	for i, b := range payload {
+		masked[i] = b ^ mask[i%4]
+	}
+	_, err := c.conn.Write(append(hdr, masked...))
+	return err
+}
+
+// readMessage returns the payload of the next text or binary message,
+// reassembling fragmented messages and answering pings.
+func (c *Conn) readMessage() ([]byte, error) {
+	var msg []byte
+	for {
+		var hdr [2]byte
+		if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+			return nil, err
+		}
+		fin := hdr[0]&0x80 != 0
+		opcode := hdr[0] & 0x0f
+		n := uint64(hdr[1] & 0x7f)
+		switch n {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+				return nil, err
+			}
+			n = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+				return nil, err
+			}
+			n = binary.BigEndian.Uint64(ext[:])
+		}
+		var mask []byte
+		if hdr[1]&0x80 != 0 {
+			mask = make([]byte, 4)
+			if _, err := io.ReadFull(c.r, mask); err != nil {
+				return nil, err
+			}
+		}
+		if n > 64<<20 {
+			return nil, fmt.Errorf("WebSocket frame too large (%d bytes)", n)
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.r, payload); err != nil {
+			return nil, err
+		}
+		if mask != nil {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return nil, fmt.Errorf("WebSocket connection closed by the browser")
+		case opText, opBinary, opContinuation:
+			msg = append(msg, payload...)
+			if fin {
+				return msg, nil
+			}
+		default:
+			return nil, fmt.Errorf("unexpected WebSocket opcode %#x", opcode)
+		}
+	}
+}
+
+// Target is a DevTools target, e.g. a browser tab.
+type Target struct {
+	TargetID string `json:"targetId"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+}
+
+// Targets returns all targets of the browser.
+func (c *Conn) Targets() ([]Target, error) {
+	var result struct {
+		TargetInfos []Target `json:"targetInfos"`
+	}
+	if err := c.Call("Target.getTargets", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.TargetInfos, nil
+}
+
+// Bounds are the bounds of a browser window, in screen coordinates.
+type Bounds struct {
+	Left   int64 `json:"left"`
+	Top    int64 `json:"top"`
+	Width  int64 `json:"width"`
+	Height int64 `json:"height"`
+}
+
+// WindowForTarget returns the id and bounds of the browser window which
+// contains the target.
+func (c *Conn) WindowForTarget(targetID string) (int64, Bounds, error) {
+	var result struct {
+		WindowID int64  `json:"windowId"`
+		Bounds   Bounds `json:"bounds"`
+	}
+	params := struct {
+		TargetID string `json:"targetId"`
+	}{targetID}
+	if err := c.Call("Browser.getWindowForTarget", params, &result); err != nil {
+		return 0, Bounds{}, err
+	}
+	return result.WindowID, result.Bounds, nil
+}
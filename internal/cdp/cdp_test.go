@@ -0,0 +1,354 @@
+package cdp
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stub is a DevTools endpoint answering like a Chromium-based browser.
+type stub struct {
+	t       *testing.T
+	srv     *httptest.Server
+	targets []Target
+	wedged  bool // accept calls, but never answer them
+	pongs   chan []byte
+}
+
+func newStub(t *testing.T, targets []Target) *stub {
+	s := &stub{t: t, targets: targets, pongs: make(chan []byte, 1)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/json/version", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Version{
+			Browser:              "Chrome/120.0.0.0",
+			WebSocketDebuggerURL: "ws://" + r.Host + "/devtools/browser/stub",
+		})
+	})
+	mux.HandleFunc("/devtools/browser/stub", s.serveWebSocket)
+	s.srv = httptest.NewServer(mux)
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+func (s *stub) port() int {
+	u, err := url.Parse(s.srv.URL)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return port
+}
+
+func (s *stub) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		http.Error(w, "not a WebSocket handshake", http.StatusBadRequest)
+		return
+	}
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		s.t.Error(err)
+		return
+	}
+	defer conn.Close()
+	h := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(h[:]))
+	if err := rw.Flush(); err != nil {
+		s.t.Error(err)
+		return
+	}
+
+	// Ping once, the client must answer with a pong.
+	writeServerFrame(conn, true, opPing, []byte("ping"))
+	for {
+		opcode, payload, err := readClientFrame(rw.Reader)
+		if err != nil {
+			return // client closed the connection
+		}
+		if opcode == opPong {
+			s.pongs <- payload
+			continue
+		}
+		if s.wedged {
+			continue
+		}
+		var req struct {
+			ID     int64           `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(payload, &req); err != nil {
+			s.t.Errorf("invalid request %q: %v", payload, err)
+			return
+		}
+		// An event arrives before the response.
+		writeServerFrame(conn, true, opText, []byte(`{"method":"Target.targetInfoChanged","params":{}}`))
+		var resp []byte
+		switch req.Method {
+		case "Target.getTargets":
+			resp, _ = json.Marshal(map[string]interface{}{
+				"id":     req.ID,
+				"result": map[string]interface{}{"targetInfos": s.targets},
+			})
+		case "Browser.getWindowForTarget":
+			var params struct {
+				TargetID string `json:"targetId"`
+			}
+			json.Unmarshal(req.Params, &params)
+			if params.TargetID == "" {
+				resp, _ = json.Marshal(map[string]interface{}{
+					"id":    req.ID,
+					"error": map[string]interface{}{"code": -32602, "message": "Invalid parameters"},
+				})
+				break
+			}
+			resp, _ = json.Marshal(map[string]interface{}{
+				"id": req.ID,
+				"result": map[string]interface{}{
+					"windowId": len(params.TargetID),
+					"bounds":   Bounds{Left: 10, Top: 20, Width: 800, Height: 600},
+				},
+			})
+		default:
+			resp, _ = json.Marshal(map[string]interface{}{
+				"id":    req.ID,
+				"error": map[string]interface{}{"code": -32601, "message": "'" + req.Method + "' wasn't found"},
+			})
+		}
+		// Send the response in two fragments.
+		half := len(resp) / 2
+		writeServerFrame(conn, false, opText, resp[:half])
+		writeServerFrame(conn, true, opContinuation, resp[half:])
+	}
+}
+
+// writeServerFrame writes an unmasked frame, as servers do.
+func writeServerFrame(w io.Writer, fin bool, opcode byte, payload []byte) error {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	hdr := []byte{b0}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = append(hdr, byte(n))
+	case n <= 0xffff:
+		hdr = append(hdr, 126, 0, 0)
+		binary.BigEndian.PutUint16(hdr[2:], uint16(n))
+	default:
+		hdr = append(hdr, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(hdr[2:], uint64(n))
+	}
+	_, err := w.Write(append(hdr, payload...))
+	return err
+}
+
+// readClientFrame reads a frame, which clients must mask.
+func readClientFrame(r *bufio.Reader) (byte, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	if hdr[0]&0x80 == 0 {
+		return 0, nil, fmt.Errorf("unexpected fragmented client frame")
+	}
+	if hdr[1]&0x80 == 0 {
+		return 0, nil, fmt.Errorf("unmasked client frame")
+	}
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return hdr[0] & 0x0f, payload, nil
+}
+
+func dialStub(t *testing.T, s *stub) *Conn {
+	t.Helper()
+	version, err := GetVersion(s.port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := version.Browser, "Chrome/120.0.0.0"; got != want {
+		t.Errorf("Browser = %q, want %q", got, want)
+	}
+	conn, err := Dial(version.WebSocketDebuggerURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestTargets(t *testing.T) {
+	for _, tt := range []struct {
+		desc    string
+		targets int // number of targets, determines the length of the response
+	}{
+		{desc: "7-bit length", targets: 0},
+		{desc: "16-bit length", targets: 3},
+		{desc: "64-bit length", targets: 1000},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			var targets []Target
+			for i := 0; i < tt.targets; i++ {
+				targets = append(targets, Target{
+					TargetID: fmt.Sprintf("%032X", i),
+					Type:     "page",
+					Title:    fmt.Sprintf("Tab %d", i),
+					URL:      fmt.Sprintf("https://example.com/%d", i),
+				})
+			}
+			conn := dialStub(t, newStub(t, targets))
+			got, err := conn.Targets()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(targets) {
+				t.Fatalf("Targets() returned %d targets, want %d", len(got), len(targets))
+			}
+			for idx := range targets {
+				if got[idx] != targets[idx] {
+					t.Errorf("Targets()[%d] = %+v, want %+v", idx, got[idx], targets[idx])
+				}
+			}
+		})
+	}
+}
+
+func TestPong(t *testing.T) {
+	s := newStub(t, nil)
+	conn := dialStub(t, s)
+	// The client answers pings while reading messages.
+	if _, err := conn.Targets(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case payload := <-s.pongs:
+		if got, want := string(payload), "ping"; got != want {
+			t.Errorf("pong payload = %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("no pong received")
+	}
+}
+
+func TestWindowForTarget(t *testing.T) {
+	conn := dialStub(t, newStub(t, nil))
+
+	// A long target ID makes the request longer than 125 bytes, i.e. the
+	// client needs to send a 16-bit length.
+	targetID := strings.Repeat("A", 200)
+	id, bounds, err := conn.WindowForTarget(targetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := id, int64(len(targetID)); got != want {
+		t.Errorf("window ID = %d, want %d", got, want)
+	}
+	if got, want := bounds, (Bounds{Left: 10, Top: 20, Width: 800, Height: 600}); got != want {
+		t.Errorf("bounds = %+v, want %+v", got, want)
+	}
+
+	_, _, err = conn.WindowForTarget("")
+	var cdpErr *Error
+	if !errors.As(err, &cdpErr) || cdpErr.Code != -32602 {
+		t.Errorf("WindowForTarget(\"\") = %v, want DevTools error -32602", err)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	defer func(old time.Duration) { timeout = old }(timeout)
+	timeout = 100 * time.Millisecond
+
+	s := newStub(t, nil)
+	s.wedged = true
+	conn := dialStub(t, s)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := conn.Targets()
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Errorf("Targets() = %v, want timeout error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Targets() did not time out")
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	defer func(old time.Duration) { timeout = old }(timeout)
+	timeout = 100 * time.Millisecond
+
+	// A server which accepts connections, but never completes the handshake.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := Dial("ws://" + ln.Addr().String() + "/devtools/browser/wedged")
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Errorf("Dial unexpectedly succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Dial did not time out")
+	}
+}
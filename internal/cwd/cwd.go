@@ -0,0 +1,225 @@
+// Package cwd determines the working directory of a workspace.
+//
+// A workspace’s working directory is configured in the cwd file of its
+// configuration directory (~/.config/wsmgr-for-i3/<name>/cwd), which is either
+// a symlink to the directory, or a plain file containing the path.
+// Alternatively, a cwd-glob file selects the most recently used git repository
+// out of all directories matching a pattern.
+//
+// When starting programs, other sources can be consulted, too. The order in
+// which sources are consulted is configured in a cwd-order file, either in the
+// workspace configuration directory, or (for all workspaces) in
+// ~/.config/wsmgr-for-i3/cwd-order.
+package cwd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/stapelberg/wsmgr-for-i3/internal/proc"
+)
+
+// Source is a way to determine the working directory.
+type Source string
+
+const (
+	// Config is the cwd file in the workspace configuration directory.
+	Config Source = "cwd"
+
+	// Glob is the cwd-glob file in the workspace configuration directory.
+	Glob Source = "cwd-glob"
+
+	// Terminal is the working directory of the shell running in the focused
+	// window, if the focused window is a terminal emulator.
+	Terminal Source = "terminal"
+)
+
+// DefaultOrder is the order in which sources are consulted when no cwd-order
+// file is present.
+var DefaultOrder = []Source{Config, Glob, Terminal}
+
+// Order reads the cwd-order file from the workspace configuration directory
+// dir, falling back to the cwd-order file in its parent directory, falling
+// back to DefaultOrder.
+//
+// A cwd-order file contains source names, separated by white space.
+func Order(dir string) ([]Source, error) {
+	for _, fn := range []string{
+		filepath.Join(dir, "cwd-order"),
+		filepath.Join(filepath.Dir(dir), "cwd-order"),
+	} {
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		var order []Source
+		for _, f := range strings.Fields(string(b)) {
+			src := Source(f)
+			switch src {
+			case Config, Glob, Terminal:
+			default:
+				return nil, fmt.Errorf("%s: unknown source %q", fn, f)
+			}
+			order = append(order, src)
+		}
+		return order, nil
+	}
+	return DefaultOrder, nil
+}
+
+// Resolver determines the working directory for a workspace.
+type Resolver struct {
+	// Dir is the workspace configuration directory, e.g.
+	// ~/.config/wsmgr-for-i3/kint.
+	Dir string
+
+	// TerminalPID returns the process id of the focused window. If nil, the
+	// Terminal source is skipped.
+	TerminalPID func() (int, error)
+}
+
+// Resolve consults the sources in the specified order and returns the first
+// directory found, or the empty string if no source yields a directory. A source
+// which fails is skipped; its error is only returned if no later source yields
+// a directory.
+func (r *Resolver) Resolve(order []Source) (string, error) {
+	var firstErr error
+	for _, src := range order {
+		dir, err := r.resolve(src)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %v", src, err)
+			}
+			continue
+		}
+		if dir != "" {
+			return dir, nil
+		}
+	}
+	return "", firstErr
+}
+
+func (r *Resolver) resolve(src Source) (string, error) {
+	switch src {
+	case Config:
+		return FromConfig(r.Dir)
+
+	case Glob:
+		return FromGlob(r.Dir)
+
+	case Terminal:
+		if r.TerminalPID == nil {
+			return "", nil
+		}
+		pid, err := r.TerminalPID()
+		if err != nil {
+			return "", nil // no focused window, or not attributable
+		}
+		return FromTerminal(pid)
+
+	default:
+		return "", fmt.Errorf("unknown source")
+	}
+}
+
+// FromConfig returns the directory configured in the cwd file of the workspace
+// configuration directory dir, or the empty string if there is no cwd file.
+//
+// If cwd is a symlink, it is resolved (relative targets are relative to dir).
+// Otherwise, cwd is read as a plain file containing a path, in which ~ and
+// environment variables are expanded (relative paths are relative to dir).
+func FromConfig(dir string) (string, error) {
+	fn := filepath.Join(dir, "cwd")
+	fi, err := os.Lstat(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	var target string
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err = filepath.EvalSymlinks(fn)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return "", err
+		}
+		target, err = Expand(strings.TrimSpace(string(b)))
+		if err != nil {
+			return "", err
+		}
+		if target == "" {
+			return "", nil
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+	}
+	st, err := os.Stat(target)
+	if err != nil {
+		return "", err
+	}
+	if !st.IsDir() {
+		return "", fmt.Errorf("%s: %s is not a directory", fn, target)
+	}
+	return target, nil
+}
+
+// Expand expands a leading ~ to the home directory and $VAR or ${VAR}
+// references to the value of the corresponding environment variable.
+func Expand(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = home + strings.TrimPrefix(path, "~")
+	}
+	return os.ExpandEnv(path), nil
+}
+
+// FromTerminal returns the working directory of the shell which runs in the
+// terminal emulator with the specified process id, or the empty string if the
+// process is not a terminal emulator.
+//
+// A process is considered a terminal emulator if it has child processes with a
+// controlling terminal (i.e. shells). The shell in the foreground of its
+// terminal (i.e. whose process group is the terminal’s foreground process
+// group) is preferred over e.g. shells which run a background job. Terminal
+// emulators which run multiple shells (e.g. in tabs, or a single server process
+// for all windows) are handled by using the most recently started shell.
+func FromTerminal(pid int) (string, error) {
+	children, err := proc.Children(pid)
+	if err != nil {
+		return "", err
+	}
+	var shells []proc.Stat
+	for _, ch := range children {
+		if ch.TTY == 0 {
+			continue
+		}
+		shells = append(shells, ch)
+	}
+	if len(shells) == 0 {
+		return "", nil // not a terminal emulator
+	}
+	foreground := func(st proc.Stat) bool { return st.PGRP == st.TPGID }
+	sort.Slice(shells, func(i, j int) bool {
+		if fi, fj := foreground(shells[i]), foreground(shells[j]); fi != fj {
+			return fi
+		}
+		return shells[i].StartTime > shells[j].StartTime
+	})
+	return proc.Cwd(shells[0].PID)
+}
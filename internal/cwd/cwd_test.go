@@ -0,0 +1,355 @@
+package cwd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// writeFile writes content to fn, creating parent directories as needed.
+func writeFile(t *testing.T, fn, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fn, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	for _, tt := range []struct {
+		desc   string
+		global string // contents of the cwd-order file for all workspaces
+		local  string // contents of the workspace’s cwd-order file
+		want   []Source
+		err    bool
+	}{
+		{
+			desc: "default",
+			want: DefaultOrder,
+		},
+
+		{
+			desc:   "global",
+			global: "terminal cwd\n",
+			want:   []Source{Terminal, Config},
+		},
+
+		{
+			desc:   "workspace overrides global",
+			global: "terminal cwd\n",
+			local:  "cwd-glob\n",
+			want:   []Source{Glob},
+		},
+
+		{
+			desc:  "unknown source",
+			local: "cwd nope\n",
+			err:   true,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			configDir := t.TempDir()
+			dir := filepath.Join(configDir, "kint")
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.global != "" {
+				writeFile(t, filepath.Join(configDir, "cwd-order"), tt.global)
+			}
+			if tt.local != "" {
+				writeFile(t, filepath.Join(dir, "cwd-order"), tt.local)
+			}
+			got, err := Order(dir)
+			if (err != nil) != tt.err {
+				t.Fatalf("Order() = %v, want error %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Order() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	src := t.TempDir()
+	if err := os.Setenv("WSMGR_TEST_SRC", src); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("WSMGR_TEST_SRC")
+
+	for _, tt := range []struct {
+		desc    string
+		symlink string // target of the cwd symlink
+		content string // contents of the cwd file
+		want    string
+		err     bool
+	}{
+		{
+			desc: "no cwd file",
+			want: "",
+		},
+
+		{
+			desc:    "absolute symlink",
+			symlink: src,
+			want:    src,
+		},
+
+		{
+			desc:    "relative symlink",
+			symlink: "../src",
+			want:    "src",
+		},
+
+		{
+			desc:    "dangling symlink",
+			symlink: filepath.Join(src, "missing"),
+			err:     true,
+		},
+
+		{
+			desc:    "symlink to a file",
+			symlink: "../file",
+			err:     true,
+		},
+
+		{
+			desc:    "plain file",
+			content: src + "\n",
+			want:    src,
+		},
+
+		{
+			desc:    "plain file with environment variable",
+			content: "$WSMGR_TEST_SRC\n",
+			want:    src,
+		},
+
+		{
+			desc:    "plain file with relative path",
+			content: "../src\n",
+			want:    "src",
+		},
+
+		{
+			desc:    "empty plain file",
+			content: "\n",
+			want:    "",
+		},
+
+		{
+			desc:    "plain file with missing directory",
+			content: filepath.Join(src, "missing"),
+			err:     true,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			configDir := t.TempDir()
+			dir := filepath.Join(configDir, "kint")
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Mkdir(filepath.Join(configDir, "src"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			writeFile(t, filepath.Join(configDir, "file"), "")
+			fn := filepath.Join(dir, "cwd")
+			if tt.symlink != "" {
+				if err := os.Symlink(tt.symlink, fn); err != nil {
+					t.Fatal(err)
+				}
+			} else if tt.content != "" {
+				writeFile(t, fn, tt.content)
+			}
+			want := tt.want
+			if want == "src" {
+				want = filepath.Join(configDir, "src")
+			}
+
+			got, err := FromConfig(dir)
+			if (err != nil) != tt.err {
+				t.Fatalf("FromConfig() = %v, want error %v", err, tt.err)
+			}
+			if got != want {
+				t.Errorf("FromConfig() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	src := t.TempDir()
+	errTerminal := errors.New("no focused window")
+	for _, tt := range []struct {
+		desc        string
+		cwd         string // target of the cwd symlink
+		glob        bool   // whether cwd-glob matches src
+		terminalPID func() (int, error)
+		order       []Source
+		want        string
+		err         string
+	}{
+		{
+			desc:  "nothing configured",
+			order: DefaultOrder,
+			want:  "",
+		},
+
+		{
+			desc:  "config",
+			cwd:   src,
+			order: DefaultOrder,
+			want:  src,
+		},
+
+		{
+			desc:  "glob",
+			glob:  true,
+			order: DefaultOrder,
+			want:  src,
+		},
+
+		{
+			desc:  "failing source is skipped",
+			cwd:   filepath.Join(src, "missing"),
+			glob:  true,
+			order: DefaultOrder,
+			want:  src,
+		},
+
+		{
+			desc:  "error is returned when no source yields a directory",
+			cwd:   filepath.Join(src, "missing"),
+			order: DefaultOrder,
+			err:   "cwd: ",
+		},
+
+		{
+			desc:  "order is respected",
+			cwd:   filepath.Join(src, "missing"),
+			glob:  true,
+			order: []Source{Config},
+			err:   "cwd: ",
+		},
+
+		{
+			desc:        "unattributable terminal is skipped",
+			terminalPID: func() (int, error) { return 0, errTerminal },
+			order:       []Source{Terminal},
+			want:        "",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.cwd != "" {
+				if err := os.Symlink(tt.cwd, filepath.Join(dir, "cwd")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.glob {
+				writeFile(t, filepath.Join(src, ".git", "HEAD"), "ref: refs/heads/main\n")
+				writeFile(t, filepath.Join(dir, "cwd-glob"), src+"\n")
+			}
+			r := &Resolver{
+				Dir:         dir,
+				TerminalPID: tt.terminalPID,
+			}
+			got, err := r.Resolve(tt.order)
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Fatalf("Resolve() = %v, want error starting with %q", err, tt.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// startOnTerminal starts a process with a new pseudo terminal as its
+// controlling terminal in dir, like a terminal emulator starts its shell.
+func startOnTerminal(t *testing.T, dir string) {
+	t.Helper()
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("pseudo terminals not available: %v", err)
+	}
+	t.Cleanup(func() { ptmx.Close() })
+	var unlock int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, ptmx.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+		t.Fatal(errno)
+	}
+	var n uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, ptmx.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); errno != 0 {
+		t.Fatal(errno)
+	}
+	pts, err := os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pts.Close()
+
+	cmd := exec.Command("sleep", "10")
+	cmd.Dir = dir
+	cmd.Stdin = pts
+	cmd.Stdout = pts
+	cmd.Stderr = pts
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+}
+
+func TestFromTerminal(t *testing.T) {
+	// The test process runs no shells, so it is not a terminal emulator.
+	if got, err := FromTerminal(os.Getpid()); err != nil {
+		t.Fatal(err)
+	} else if got != "" {
+		t.Errorf("FromTerminal(not a terminal) = %q, want \"\"", got)
+	}
+
+	// Processes without a controlling terminal are not considered shells.
+	other := exec.Command("sleep", "10")
+	other.Dir = t.TempDir()
+	if err := other.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		other.Process.Kill()
+		other.Wait()
+	}()
+
+	// The most recently started shell is used. Start times are measured in
+	// clock ticks, so wait for the next one.
+	older := t.TempDir()
+	startOnTerminal(t, older)
+	time.Sleep(50 * time.Millisecond)
+	newer := t.TempDir()
+	startOnTerminal(t, newer)
+
+	got, err := FromTerminal(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != newer {
+		t.Errorf("FromTerminal() = %q, want %q", got, newer)
+	}
+}
@@ -0,0 +1,113 @@
+package cwd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FromGlob returns the most recently used git repository matching the pattern
+// configured in the cwd-glob file of the workspace configuration directory dir,
+// or the empty string if there is no cwd-glob file or no git repository matches.
+//
+// The pattern is a filepath.Match pattern, in which ~ and environment
+// variables are expanded, e.g. ~/kint/*.
+func FromGlob(dir string) (string, error) {
+	fn := filepath.Join(dir, "cwd-glob")
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	pattern, err := Expand(strings.TrimSpace(string(b)))
+	if err != nil {
+		return "", err
+	}
+	if pattern == "" {
+		return "", nil
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", fn, err)
+	}
+	var (
+		newest     string
+		newestTime time.Time
+	)
+	for _, match := range matches {
+		t, err := gitActivity(match)
+		if err != nil {
+			continue // not a git repository
+		}
+		if t.After(newestTime) {
+			newest = match
+			newestTime = t
+		}
+	}
+	return newest, nil
+}
+
+// gitDir returns the git directory of the git working tree dir. Linked
+// working trees (git worktree) and submodules use a .git file which points to
+// the git directory.
+func gitDir(dir string) (string, error) {
+	gd := filepath.Join(dir, ".git")
+	fi, err := os.Stat(gd)
+	if err != nil {
+		return "", err
+	}
+	if fi.IsDir() {
+		return gd, nil
+	}
+	b, err := ioutil.ReadFile(gd)
+	if err != nil {
+		return "", err
+	}
+	const prefix = "gitdir: "
+	line := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(line, prefix) {
+		return "", fmt.Errorf("%s: unexpected contents", gd)
+	}
+	target := strings.TrimPrefix(line, prefix)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+	return target, nil
+}
+
+// gitActivity returns the time of the most recent activity in the git working
+// tree dir, i.e. the most recent modification time of the checked out ref
+// (HEAD and its reflog), the index, and the working tree’s top-level directory.
+func gitActivity(dir string) (time.Time, error) {
+	gd, err := gitDir(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if _, err := os.Stat(filepath.Join(gd, "HEAD")); err != nil {
+		return time.Time{}, err
+	}
+	var newest time.Time
+	for _, fn := range []string{
+		filepath.Join(gd, "HEAD"),
+		filepath.Join(gd, "logs", "HEAD"),
+		filepath.Join(gd, "index"),
+		dir,
+	} {
+		fi, err := os.Stat(fn)
+		if err != nil {
+			continue // e.g. no reflog
+		}
+		if mt := fi.ModTime(); mt.After(newest) {
+			newest = mt
+		}
+	}
+	return newest, nil
+}
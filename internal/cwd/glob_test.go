@@ -0,0 +1,239 @@
+package cwd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeRepo creates a git working tree in dir whose most recent activity was
+// at mtime.
+func writeRepo(t *testing.T, dir string, mtime time.Time) {
+	t.Helper()
+	gd := filepath.Join(dir, ".git")
+	writeFile(t, filepath.Join(gd, "HEAD"), "ref: refs/heads/main\n")
+	writeFile(t, filepath.Join(gd, "logs", "HEAD"), "")
+	writeFile(t, filepath.Join(gd, "index"), "")
+	for _, fn := range []string{
+		filepath.Join(gd, "HEAD"),
+		filepath.Join(gd, "logs", "HEAD"),
+		filepath.Join(gd, "index"),
+		dir,
+	} {
+		if err := os.Chtimes(fn, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// touch sets the modification time of fn to mtime.
+func touch(t *testing.T, fn string, mtime time.Time) {
+	t.Helper()
+	if err := os.Chtimes(fn, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGitDir(t *testing.T) {
+	root := t.TempDir()
+	writeRepo(t, filepath.Join(root, "main"), time.Now())
+	wtGitDir := filepath.Join(root, "main", ".git", "worktrees", "wt")
+	writeFile(t, filepath.Join(wtGitDir, "HEAD"), "ref: refs/heads/feature\n")
+
+	for _, tt := range []struct {
+		desc    string
+		dotGit  string // contents of the .git file, if not a directory
+		dir     string
+		want    string
+		wantErr bool
+	}{
+		{
+			desc: "directory",
+			dir:  filepath.Join(root, "main"),
+			want: filepath.Join(root, "main", ".git"),
+		},
+
+		{
+			desc:   "absolute gitdir file",
+			dotGit: "gitdir: " + wtGitDir + "\n",
+			want:   wtGitDir,
+		},
+
+		{
+			desc:   "relative gitdir file",
+			dotGit: "gitdir: ../main/.git/worktrees/wt\n",
+			want:   wtGitDir,
+		},
+
+		{
+			desc:    "malformed gitdir file",
+			dotGit:  "nope\n",
+			wantErr: true,
+		},
+
+		{
+			desc:    "not a git working tree",
+			dir:     root,
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			dir := tt.dir
+			if dir == "" {
+				dir = filepath.Join(root, "wt")
+				writeFile(t, filepath.Join(dir, ".git"), tt.dotGit)
+			}
+			got, err := gitDir(dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("gitDir() = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("gitDir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitActivity(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	writeRepo(t, dir, old)
+	if got, err := gitActivity(dir); err != nil {
+		t.Fatal(err)
+	} else if !got.Equal(old) {
+		t.Errorf("gitActivity() = %v, want %v", got, old)
+	}
+
+	// Any of HEAD, its reflog, the index and the working tree count.
+	for i, fn := range []string{
+		filepath.Join(dir, ".git", "HEAD"),
+		filepath.Join(dir, ".git", "logs", "HEAD"),
+		filepath.Join(dir, ".git", "index"),
+		dir,
+	} {
+		mt := old.Add(time.Duration(i+1) * time.Hour)
+		touch(t, fn, mt)
+		if got, err := gitActivity(dir); err != nil {
+			t.Fatal(err)
+		} else if !got.Equal(mt) {
+			t.Errorf("gitActivity() after touching %s = %v, want %v", fn, got, mt)
+		}
+	}
+
+	// A .git directory without HEAD is not a git repository.
+	empty := t.TempDir()
+	if err := os.Mkdir(filepath.Join(empty, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gitActivity(empty); err == nil {
+		t.Errorf("gitActivity(no HEAD) = nil error, want error")
+	}
+}
+
+func TestFromGlob(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	for _, tt := range []struct {
+		desc    string
+		pattern string
+		setup   func(t *testing.T, root string)
+		want    string // relative to root
+	}{
+		{
+			desc: "no cwd-glob file",
+			want: "",
+		},
+
+		{
+			desc:    "no matches",
+			pattern: "src/*",
+			want:    "",
+		},
+
+		{
+			desc:    "no git repositories",
+			pattern: "src/*",
+			setup: func(t *testing.T, root string) {
+				writeFile(t, filepath.Join(root, "src", "a", "README"), "")
+			},
+			want: "",
+		},
+
+		{
+			desc:    "most recently used",
+			pattern: "src/*",
+			setup: func(t *testing.T, root string) {
+				writeRepo(t, filepath.Join(root, "src", "a"), now.Add(-2*time.Hour))
+				writeRepo(t, filepath.Join(root, "src", "b"), now.Add(-1*time.Hour))
+				writeRepo(t, filepath.Join(root, "src", "c"), now.Add(-3*time.Hour))
+				writeFile(t, filepath.Join(root, "src", "d", "README"), "")
+			},
+			want: "src/b",
+		},
+
+		{
+			desc:    "a commit makes a repository the most recently used",
+			pattern: "src/*",
+			setup: func(t *testing.T, root string) {
+				writeRepo(t, filepath.Join(root, "src", "a"), now.Add(-2*time.Hour))
+				writeRepo(t, filepath.Join(root, "src", "b"), now.Add(-1*time.Hour))
+				touch(t, filepath.Join(root, "src", "a", ".git", "logs", "HEAD"), now)
+			},
+			want: "src/a",
+		},
+
+		{
+			desc:    "dangling symlinks are skipped",
+			pattern: "src/*",
+			setup: func(t *testing.T, root string) {
+				writeRepo(t, filepath.Join(root, "src", "a"), now.Add(-1*time.Hour))
+				if err := os.Symlink(filepath.Join(root, "missing"), filepath.Join(root, "src", "b")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "src/a",
+		},
+
+		{
+			desc:    "linked working tree",
+			pattern: "src/*",
+			setup: func(t *testing.T, root string) {
+				writeRepo(t, filepath.Join(root, "main"), now.Add(-2*time.Hour))
+				gd := filepath.Join(root, "main", ".git", "worktrees", "wt")
+				writeFile(t, filepath.Join(gd, "HEAD"), "ref: refs/heads/feature\n")
+				touch(t, filepath.Join(gd, "HEAD"), now)
+				wt := filepath.Join(root, "src", "wt")
+				writeFile(t, filepath.Join(wt, ".git"), "gitdir: ../../main/.git/worktrees/wt\n")
+				touch(t, wt, now.Add(-3*time.Hour))
+				writeRepo(t, filepath.Join(root, "src", "a"), now.Add(-1*time.Hour))
+			},
+			want: "src/wt",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "kint")
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.setup != nil {
+				tt.setup(t, root)
+			}
+			if tt.pattern != "" {
+				writeFile(t, filepath.Join(dir, "cwd-glob"), filepath.Join(root, tt.pattern)+"\n")
+			}
+			want := tt.want
+			if want != "" {
+				want = filepath.Join(root, want)
+			}
+
+			got, err := FromGlob(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("FromGlob() = %q, want %q", got, want)
+			}
+		})
+	}
+}
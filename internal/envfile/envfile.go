@@ -0,0 +1,174 @@
+// Package envfile reads environment files in dotenv format.
+//
+// Each line contains a KEY=value assignment, optionally prefixed with
+// "export". Empty lines and lines starting with # are ignored.
+//
+// Values can be unquoted, single-quoted or double-quoted. In unquoted and
+// double-quoted values, ${VAR} and $VAR references are expanded, using
+// variables assigned earlier in the file and the inherited environment. In
+// double-quoted values, \$ is a literal $. Single-quoted values are used
+// literally.
+//
+// Assignments to PATH are prepended to the inherited PATH, unless the value
+// references $PATH itself. A leading ~ in PATH elements is expanded to the home
+// directory.
+package envfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Apply reads the environment file fn and applies its assignments to env,
+// which is a list of KEY=value strings like returned by os.Environ. If fn does
+// not exist, env is returned unchanged.
+func Apply(env []string, fn string) ([]string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return env, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	env, err = Parse(env, f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return env, nil
+}
+
+// Parse applies the assignments read from r to env.
+func Parse(env []string, r io.Reader) ([]string, error) {
+	vars := make(map[string]string)
+	var order []string
+	for _, kv := range env {
+		idx := strings.IndexByte(kv, '=')
+		if idx == -1 {
+			continue
+		}
+		key := kv[:idx]
+		if _, ok := vars[key]; !ok {
+			order = append(order, key)
+		}
+		vars[key] = kv[idx+1:]
+	}
+	lookup := func(key string) string { return vars[key] }
+
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		idx := strings.IndexByte(line, '=')
+		if idx < 1 {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineno)
+		}
+		key := strings.TrimSpace(line[:idx])
+		value, err := parseValue(strings.TrimSpace(line[idx+1:]), lookup)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineno, err)
+		}
+		if key == "PATH" {
+			value, err = prependPath(line[idx+1:], value, vars["PATH"])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineno, err)
+			}
+		}
+		if _, ok := vars[key]; !ok {
+			order = append(order, key)
+		}
+		vars[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(order))
+	for _, key := range order {
+		result = append(result, key+"="+vars[key])
+	}
+	return result, nil
+}
+
+func parseValue(raw string, lookup func(string) string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	switch raw[0] {
+	case '\'':
+		end := strings.IndexByte(raw[1:], '\'')
+		if end == -1 {
+			return "", fmt.Errorf("unterminated single-quoted value")
+		}
+		return raw[1 : end+1], nil
+
+	case '"':
+		// sb contains the text since the last \$, which is expanded once
+		// the next \$ (or the end of the value) is reached.
+		var expanded, sb strings.Builder
+		for i := 1; i < len(raw); i++ {
+			switch raw[i] {
+			case '"':
+				expanded.WriteString(os.Expand(sb.String(), lookup))
+				return expanded.String(), nil
+			case '\\':
+				if i+1 < len(raw) {
+					i++
+					switch raw[i] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 't':
+						sb.WriteByte('\t')
+					case '$':
+						expanded.WriteString(os.Expand(sb.String(), lookup))
+						expanded.WriteByte('$')
+						sb.Reset()
+					default:
+						sb.WriteByte(raw[i])
+					}
+					continue
+				}
+			}
+			sb.WriteByte(raw[i])
+		}
+		return "", fmt.Errorf("unterminated double-quoted value")
+
+	default:
+		// Strip trailing comments
+		if idx := strings.Index(raw, " #"); idx > -1 {
+			raw = strings.TrimSpace(raw[:idx])
+		}
+		return os.Expand(raw, lookup), nil
+	}
+}
+
+// prependPath implements the PATH prepend semantics: unless the raw (not yet
+// expanded) value references $PATH, the inherited PATH is appended to value.
+func prependPath(raw, value, inherited string) (string, error) {
+	var elems []string
+	for _, elem := range filepath.SplitList(value) {
+		if elem == "~" || strings.HasPrefix(elem, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			elem = home + strings.TrimPrefix(elem, "~")
+		}
+		elems = append(elems, elem)
+	}
+	value = strings.Join(elems, string(filepath.ListSeparator))
+	if strings.Contains(raw, "$PATH") || strings.Contains(raw, "${PATH}") {
+		return value, nil
+	}
+	if inherited == "" {
+		return value, nil
+	}
+	return value + string(filepath.ListSeparator) + inherited, nil
+}
@@ -0,0 +1,136 @@
+package envfile
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inherited := []string{
+		"HOME=/home/michael",
+		"PATH=/usr/bin:/bin",
+	}
+	for _, tt := range []struct {
+		desc    string
+		content string
+		want    []string // resulting environment
+	}{
+		{
+			desc:    "comments and empty lines",
+			content: "# comment\n\nA=1\n",
+			want:    []string{"HOME=/home/michael", "PATH=/usr/bin:/bin", "A=1"},
+		},
+
+		{
+			desc:    "export prefix",
+			content: "export A=1\n",
+			want:    []string{"HOME=/home/michael", "PATH=/usr/bin:/bin", "A=1"},
+		},
+
+		{
+			desc:    "unquoted with trailing comment",
+			content: "A=value # comment\n",
+			want:    []string{"HOME=/home/michael", "PATH=/usr/bin:/bin", "A=value"},
+		},
+
+		{
+			desc:    "single-quoted is literal",
+			content: `A='${HOME} \n $B # x'` + "\n",
+			want:    []string{"HOME=/home/michael", "PATH=/usr/bin:/bin", `A=${HOME} \n $B # x`},
+		},
+
+		{
+			desc:    "double-quoted escapes",
+			content: `A="a\"b\\c\nd\te"` + "\n",
+			want:    []string{"HOME=/home/michael", "PATH=/usr/bin:/bin", "A=a\"b\\c\nd\te"},
+		},
+
+		{
+			desc:    "double-quoted expands",
+			content: `A="${HOME}/x $HOME"` + "\n",
+			want:    []string{"HOME=/home/michael", "PATH=/usr/bin:/bin", "A=/home/michael/x /home/michael"},
+		},
+
+		{
+			desc:    "double-quoted escaped dollar",
+			content: `A="\$HOME is ${HOME}, costs \$5"` + "\n",
+			want:    []string{"HOME=/home/michael", "PATH=/usr/bin:/bin", "A=$HOME is /home/michael, costs $5"},
+		},
+
+		{
+			desc:    "references earlier assignments",
+			content: "A=kint\nB=${A}/src\nC=${UNSET}x\n",
+			want:    []string{"HOME=/home/michael", "PATH=/usr/bin:/bin", "A=kint", "B=kint/src", "C=x"},
+		},
+
+		{
+			desc:    "overrides keep their position",
+			content: "HOME=/tmp\n",
+			want:    []string{"HOME=/tmp", "PATH=/usr/bin:/bin"},
+		},
+
+		{
+			desc:    "PATH is prepended",
+			content: "PATH=/opt/bin\n",
+			want:    []string{"HOME=/home/michael", "PATH=/opt/bin:/usr/bin:/bin"},
+		},
+
+		{
+			desc:    "PATH referencing itself is not prepended",
+			content: "PATH=${PATH}:/opt/bin\n",
+			want:    []string{"HOME=/home/michael", "PATH=/usr/bin:/bin:/opt/bin"},
+		},
+
+		{
+			desc:    "PATH referencing $PATH is not prepended",
+			content: `PATH="/opt/bin:$PATH"` + "\n",
+			want:    []string{"HOME=/home/michael", "PATH=/opt/bin:/usr/bin:/bin"},
+		},
+
+		{
+			desc:    "PATH tilde expansion",
+			content: "PATH=~/bin:~\n",
+			want:    []string{"HOME=/home/michael", "PATH=" + home + "/bin:" + home + ":/usr/bin:/bin"},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := Parse(inherited, strings.NewReader(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, content := range []string{
+		"no assignment\n",
+		"=value\n",
+		"A='unterminated\n",
+		`A="unterminated` + "\n",
+	} {
+		if _, err := Parse(nil, strings.NewReader(content)); err == nil {
+			t.Errorf("Parse(%q) unexpectedly succeeded", content)
+		}
+	}
+}
+
+func TestApplyMissingFile(t *testing.T) {
+	env := []string{"A=1"}
+	got, err := Apply(env, "/nonexistent/env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, env) {
+		t.Errorf("Apply(missing file) = %q, want %q", got, env)
+	}
+}
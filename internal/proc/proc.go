@@ -0,0 +1,93 @@
+// Package proc reads process information from the Linux /proc file system.
+package proc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Stat contains the fields of /proc/<pid>/stat which wsmgr-for-i3 uses. See
+// proc(5) for details.
+type Stat struct {
+	PID       int
+	PPID      int
+	PGRP      int
+	Session   int
+	TTY       int    // controlling terminal, 0 if none
+	TPGID     int    // foreground process group of the controlling terminal
+	StartTime uint64 // in clock ticks after system boot
+}
+
+// ReadStat parses /proc/<pid>/stat.
+func ReadStat(pid int) (Stat, error) {
+	b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return Stat{}, err
+	}
+	// The second field (comm) is in parentheses and may contain spaces, so
+	// start parsing after its closing parenthesis.
+	stat := string(b)
+	idx := strings.LastIndexByte(stat, ')')
+	if idx == -1 {
+		return Stat{}, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	// Field 3 (state) is fields[0], so field n is fields[n-3].
+	fields := strings.Fields(stat[idx+1:])
+	if len(fields) < 20 {
+		return Stat{}, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	st := Stat{PID: pid}
+	for _, f := range []struct {
+		field int
+		dest  *int
+	}{
+		{4, &st.PPID},
+		{5, &st.PGRP},
+		{6, &st.Session},
+		{7, &st.TTY},
+		{8, &st.TPGID},
+	} {
+		*f.dest, err = strconv.Atoi(fields[f.field-3])
+		if err != nil {
+			return Stat{}, fmt.Errorf("/proc/%d/stat: field %d: %v", pid, f.field, err)
+		}
+	}
+	st.StartTime, err = strconv.ParseUint(fields[22-3], 0, 64)
+	if err != nil {
+		return Stat{}, fmt.Errorf("/proc/%d/stat: field 22: %v", pid, err)
+	}
+	return st, nil
+}
+
+// Children returns the stat of all processes whose parent is pid.
+func Children(pid int) ([]Stat, error) {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var children []Stat
+	for _, e := range entries {
+		child, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue // not a process
+		}
+		st, err := ReadStat(child)
+		if err != nil {
+			continue // process exited in the meantime
+		}
+		if st.PPID == pid {
+			children = append(children, st)
+		}
+	}
+	return children, nil
+}
+
+// Cwd returns the current working directory of the process with the specified
+// pid.
+func Cwd(pid int) (string, error) {
+	return os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "cwd"))
+}
@@ -0,0 +1,450 @@
+// Package sqlite is a minimal, read-only reader for SQLite database files,
+// written in pure Go so that programs using it build without cgo.
+//
+// Only what is required to read all rows of a table is implemented: table
+// b-trees (including overflow pages), records, UTF-8 text, and the
+// write-ahead log (committed frames are applied on top of the database).
+// Indexes, WITHOUT ROWID tables and SQL are not supported.
+//
+// See https://www.sqlite.org/fileformat2.html for the file format.
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+)
+
+// DB is an in-memory copy of an SQLite database.
+type DB struct {
+	data     []byte
+	wal      map[uint32][]byte // page number to page contents
+	pageSize int
+	usable   int // page size minus reserved bytes
+	numPages uint32
+}
+
+// Open reads the SQLite database file fn and its write-ahead log (fn-wal), if
+// any, into memory. Subsequent changes to the files do not affect the DB. This
+// works even while another process (e.g. Firefox) holds a lock on the database.
+func Open(fn string) (*DB, error) {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	wal, err := ioutil.ReadFile(fn + "-wal")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	db, err := Parse(data, wal)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return db, nil
+}
+
+// Parse returns a DB for the contents of a database file and its write-ahead
+// log (which may be nil).
+func Parse(data, wal []byte) (*DB, error) {
+	const magic = "SQLite format 3\x00"
+	if len(data) < 100 || string(data[:len(magic)]) != magic {
+		return nil, fmt.Errorf("not an SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("invalid page size %d", pageSize)
+	}
+	if enc := binary.BigEndian.Uint32(data[56:]); enc != 1 && enc != 0 {
+		return nil, fmt.Errorf("unsupported text encoding %d (only UTF-8 is supported)", enc)
+	}
+	db := &DB{
+		data:     data,
+		pageSize: pageSize,
+		usable:   pageSize - int(data[20]),
+		numPages: uint32(len(data) / pageSize),
+	}
+	if err := db.applyWAL(wal); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// applyWAL reads all committed frames of the write-ahead log. Frames after the
+// last valid commit frame (e.g. of an in-progress transaction) are ignored.
+func (db *DB) applyWAL(wal []byte) error {
+	if len(wal) < 32 {
+		return nil
+	}
+	var order binary.ByteOrder
+	switch binary.BigEndian.Uint32(wal) {
+	case 0x377f0682:
+		order = binary.LittleEndian
+	case 0x377f0683:
+		order = binary.BigEndian
+	default:
+		return fmt.Errorf("invalid write-ahead log header")
+	}
+	if ps := int(binary.BigEndian.Uint32(wal[8:])); ps != db.pageSize {
+		return fmt.Errorf("write-ahead log page size %d does not match database page size %d", ps, db.pageSize)
+	}
+	s0, s1 := walChecksum(order, 0, 0, wal[:24])
+	if s0 != binary.BigEndian.Uint32(wal[24:]) || s1 != binary.BigEndian.Uint32(wal[28:]) {
+		return nil // invalid header: the log contains no valid frames
+	}
+	salt := wal[16:24]
+	db.wal = make(map[uint32][]byte)
+	pending := make(map[uint32][]byte)
+	for off := 32; off+24+db.pageSize <= len(wal); off += 24 + db.pageSize {
+		hdr := wal[off : off+24]
+		page := wal[off+24 : off+24+db.pageSize]
+		if !bytes.Equal(hdr[8:16], salt) {
+			break // frame of a previous generation of the log
+		}
+		s0, s1 = walChecksum(order, s0, s1, hdr[:8])
+		s0, s1 = walChecksum(order, s0, s1, page)
+		if s0 != binary.BigEndian.Uint32(hdr[16:]) || s1 != binary.BigEndian.Uint32(hdr[20:]) {
+			break // partially written frame
+		}
+		pending[binary.BigEndian.Uint32(hdr)] = page
+		if commit := binary.BigEndian.Uint32(hdr[4:]); commit != 0 {
+			for pgno, page := range pending {
+				db.wal[pgno] = page
+			}
+			pending = make(map[uint32][]byte)
+			db.numPages = commit
+		}
+	}
+	return nil
+}
+
+func walChecksum(order binary.ByteOrder, s0, s1 uint32, b []byte) (uint32, uint32) {
+	for i := 0; i+8 <= len(b); i += 8 {
+		s0 += order.Uint32(b[i:]) + s1
+		s1 += order.Uint32(b[i+4:]) + s0
+	}
+	return s0, s1
+}
+
+func (db *DB) page(pgno uint32) ([]byte, error) {
+	if pgno < 1 || pgno > db.numPages {
+		return nil, fmt.Errorf("page %d out of range", pgno)
+	}
+	if page, ok := db.wal[pgno]; ok {
+		return page, nil
+	}
+	off := int(pgno-1) * db.pageSize
+	if off+db.pageSize > len(db.data) {
+		return nil, fmt.Errorf("page %d beyond end of file", pgno)
+	}
+	return db.data[off : off+db.pageSize], nil
+}
+
+// Row is a table row, mapping column names to values. Values are of type
+// int64, float64, string, []byte, or nil (NULL).
+type Row map[string]interface{}
+
+// Int64 returns the value of the specified column if it is an integer, or 0.
+func (r Row) Int64(column string) int64 {
+	v, _ := r[column].(int64)
+	return v
+}
+
+// String returns the value of the specified column if it is text, or "".
+func (r Row) String(column string) string {
+	v, _ := r[column].(string)
+	return v
+}
+
+// Table returns all rows of the specified table, in rowid order.
+func (db *DB) Table(name string) ([]Row, error) {
+	var (
+		rootpage int64
+		sql      string
+	)
+	err := db.walk(1, func(rowid int64, values []interface{}) error {
+		// sqlite_schema columns: type, name, tbl_name, rootpage, sql
+		if len(values) < 5 {
+			return nil
+		}
+		if typ, _ := values[0].(string); typ != "table" {
+			return nil
+		}
+		if n, _ := values[1].(string); n != name {
+			return nil
+		}
+		rootpage, _ = values[3].(int64)
+		sql, _ = values[4].(string)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rootpage == 0 {
+		return nil, fmt.Errorf("table %q not found", name)
+	}
+	columns, rowidColumn, err := parseColumns(sql)
+	if err != nil {
+		return nil, fmt.Errorf("table %q: %v", name, err)
+	}
+	var rows []Row
+	err = db.walk(uint32(rootpage), func(rowid int64, values []interface{}) error {
+		row := make(Row, len(columns))
+		for idx, col := range columns {
+			if idx < len(values) {
+				row[col] = values[idx]
+			} else {
+				row[col] = nil // column added after the row was written
+			}
+		}
+		if rowidColumn != "" {
+			row[rowidColumn] = rowid
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("table %q: %v", name, err)
+	}
+	return rows, nil
+}
+
+// parseColumns returns the column names of a CREATE TABLE statement, and the
+// name of the column which is an alias for the rowid (INTEGER PRIMARY KEY), if
+// any.
+func parseColumns(sql string) (columns []string, rowidColumn string, _ error) {
+	start := strings.IndexByte(sql, '(')
+	end := strings.LastIndexByte(sql, ')')
+	if start == -1 || end < start {
+		return nil, "", fmt.Errorf("cannot parse schema %q", sql)
+	}
+	var defs []string
+	depth := 0
+	last := start + 1
+	for i := start + 1; i < end; i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				defs = append(defs, sql[last:i])
+				last = i + 1
+			}
+		}
+	}
+	defs = append(defs, sql[last:end])
+	for _, def := range defs {
+		fields := strings.Fields(def)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PRIMARY", "UNIQUE", "CHECK", "FOREIGN", "CONSTRAINT":
+			continue // table constraint
+		}
+		name := strings.Trim(fields[0], "\"`[]'")
+		columns = append(columns, name)
+		upper := strings.ToUpper(strings.Join(fields[1:], " "))
+		if strings.HasPrefix(upper, "INTEGER") && strings.Contains(upper, "PRIMARY KEY") {
+			rowidColumn = name
+		}
+	}
+	return columns, rowidColumn, nil
+}
+
+// walk calls fn for each row of the table b-tree with root page pgno.
+func (db *DB) walk(pgno uint32, fn func(rowid int64, values []interface{}) error) error {
+	return db.walkPage(pgno, fn, 0)
+}
+
+func (db *DB) walkPage(pgno uint32, fn func(rowid int64, values []interface{}) error, depth int) error {
+	if depth > 64 {
+		return fmt.Errorf("b-tree too deep (corrupt database?)")
+	}
+	page, err := db.page(pgno)
+	if err != nil {
+		return err
+	}
+	hdr := 0
+	if pgno == 1 {
+		hdr = 100 // database header
+	}
+	if len(page) < hdr+12 {
+		return fmt.Errorf("page %d: truncated", pgno)
+	}
+	typ := page[hdr]
+	numCells := int(binary.BigEndian.Uint16(page[hdr+3:]))
+	cellPtrs := hdr + 8
+	if typ == 0x05 {
+		cellPtrs = hdr + 12
+	}
+	if cellPtrs+2*numCells > len(page) {
+		return fmt.Errorf("page %d: truncated cell pointer array", pgno)
+	}
+	for i := 0; i < numCells; i++ {
+		off := int(binary.BigEndian.Uint16(page[cellPtrs+2*i:]))
+		if off >= len(page) {
+			return fmt.Errorf("page %d: cell %d out of bounds", pgno, i)
+		}
+		cell := page[off:]
+		switch typ {
+		case 0x05: // interior table b-tree page
+			if len(cell) < 4 {
+				return fmt.Errorf("page %d: truncated cell %d", pgno, i)
+			}
+			if err := db.walkPage(binary.BigEndian.Uint32(cell), fn, depth+1); err != nil {
+				return err
+			}
+
+		case 0x0d: // leaf table b-tree page
+			payloadSize, n := varint(cell)
+			cell = cell[n:]
+			rowid, n := varint(cell)
+			cell = cell[n:]
+			payload, err := db.payload(cell, int(payloadSize))
+			if err != nil {
+				return fmt.Errorf("page %d: cell %d: %v", pgno, i, err)
+			}
+			values, err := decodeRecord(payload)
+			if err != nil {
+				return fmt.Errorf("page %d: cell %d: %v", pgno, i, err)
+			}
+			if err := fn(int64(rowid), values); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("page %d: not a table b-tree page (type %#x)", pgno, typ)
+		}
+	}
+	if typ == 0x05 {
+		return db.walkPage(binary.BigEndian.Uint32(page[hdr+8:]), fn, depth+1)
+	}
+	return nil
+}
+
+// payload returns the complete payload of a table leaf cell, following the
+// overflow page chain if necessary.
+func (db *DB) payload(cell []byte, size int) ([]byte, error) {
+	u := db.usable
+	x := u - 35
+	local := size
+	if size > x {
+		m := ((u-12)*32)/255 - 23
+		local = m + (size-m)%(u-4)
+		if local > x {
+			local = m
+		}
+	}
+	if local > len(cell) {
+		return nil, fmt.Errorf("truncated payload")
+	}
+	if local == size {
+		return cell[:size], nil
+	}
+	if local+4 > len(cell) {
+		return nil, fmt.Errorf("truncated payload")
+	}
+	payload := make([]byte, 0, size)
+	payload = append(payload, cell[:local]...)
+	next := binary.BigEndian.Uint32(cell[local:])
+	for len(payload) < size {
+		if next == 0 {
+			return nil, fmt.Errorf("overflow chain ends prematurely")
+		}
+		page, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		next = binary.BigEndian.Uint32(page)
+		n := size - len(payload)
+		if n > u-4 {
+			n = u - 4
+		}
+		payload = append(payload, page[4:4+n]...)
+	}
+	return payload, nil
+}
+
+// varint decodes an SQLite variable-length integer and returns it and the
+// number of bytes read.
+func varint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
+
+func decodeRecord(payload []byte) ([]interface{}, error) {
+	hdrSize, n := varint(payload)
+	if n == 0 || hdrSize < uint64(n) || hdrSize > uint64(len(payload)) {
+		return nil, fmt.Errorf("invalid record header")
+	}
+	hdr := payload[n:hdrSize]
+	body := payload[hdrSize:]
+	var values []interface{}
+	for len(hdr) > 0 {
+		serialType, n := varint(hdr)
+		hdr = hdr[n:]
+		var size int
+		switch {
+		case serialType <= 4:
+			size = int(serialType)
+		case serialType == 5:
+			size = 6
+		case serialType == 6, serialType == 7:
+			size = 8
+		case serialType == 8, serialType == 9:
+			size = 0
+		case serialType >= 12:
+			size = int(serialType-12) / 2
+		default:
+			return nil, fmt.Errorf("invalid serial type %d", serialType)
+		}
+		if size > len(body) {
+			return nil, fmt.Errorf("truncated record")
+		}
+		b := body[:size]
+		body = body[size:]
+		switch {
+		case serialType == 0:
+			values = append(values, nil)
+		case serialType <= 6:
+			// big-endian two’s complement integer of size bytes
+			var v int64
+			if b[0]&0x80 != 0 {
+				v = -1
+			}
+			for _, c := range b {
+				v = v<<8 | int64(c)
+			}
+			values = append(values, v)
+		case serialType == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(b)))
+		case serialType == 8:
+			values = append(values, int64(0))
+		case serialType == 9:
+			values = append(values, int64(1))
+		case serialType%2 == 0:
+			values = append(values, append([]byte(nil), b...))
+		default:
+			values = append(values, string(b))
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,252 @@
+package sqlite
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The fixtures were created with the sqlite3 command line tool:
+//
+//	sqlite3 testdata/places.sqlite < testdata/places.sql
+//
+// testdata/wal/ contains a copy of places.sqlite in WAL mode with the changes
+// of testdata/places-wal.sql committed to its write-ahead log. The files were
+// copied while the connection was still open, i.e. before SQLite checkpointed
+// the log into the database file.
+
+func bookmarksByID(t *testing.T, db *DB) map[int64]Row {
+	t.Helper()
+	rows, err := db.Table("moz_bookmarks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[int64]Row, len(rows))
+	for _, r := range rows {
+		byID[r.Int64("id")] = r
+	}
+	return byID
+}
+
+func TestTable(t *testing.T) {
+	db, err := Open(filepath.Join("testdata", "places.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	places, err := db.Table("moz_places")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// moz_places spans multiple leaf pages below an interior page.
+	if got, want := len(places), 102; got != want {
+		t.Fatalf("len(moz_places) = %d, want %d", got, want)
+	}
+	for idx, p := range places {
+		if got, want := p.Int64("id"), int64(idx+1); got != want {
+			t.Fatalf("moz_places[%d].id = %d, want %d (rowid order)", idx, got, want)
+		}
+	}
+	if got, want := places[41].String("url"), "https://example.com/page/42"; got != want {
+		t.Errorf("moz_places[41].url = %q, want %q", got, want)
+	}
+	if got, want := places[41].Int64("frecency"), int64(4200); got != want {
+		t.Errorf("moz_places[41].frecency = %d, want %d", got, want)
+	}
+	if got, want := places[41].Int64("url_hash"), int64(-42*1000003); got != want {
+		t.Errorf("moz_places[41].url_hash = %d, want %d", got, want)
+	}
+	if got := places[100].String("url"); !strings.HasPrefix(got, "https://example.com/long?q=xxx") || len(got) != 3027 {
+		t.Errorf("moz_places[100].url = %.40q… (len %d), want the 3027 byte URL (stored in overflow pages)", got, len(got))
+	}
+	if got, want := places[101].String("title"), "Grüße – ♫"; got != want {
+		t.Errorf("moz_places[101].title = %q, want %q", got, want)
+	}
+
+	bookmarks := bookmarksByID(t, db)
+	if got, want := len(bookmarks), 11; got != want {
+		t.Fatalf("len(moz_bookmarks) = %d, want %d", got, want)
+	}
+	for _, tt := range []struct {
+		id     int64
+		title  string
+		fk     int64
+		parent int64
+		guid   string
+	}{
+		{id: 3, title: "toolbar", parent: 1, guid: "toolbar_____"},
+		{id: 6, title: "kint", parent: 3, guid: "kintfolder01"},
+		{id: 8, title: "Long", fk: 101, parent: 6, guid: "bookmark0002"},
+		{id: 9, title: "", parent: 6, guid: "separator001"},
+	} {
+		b := bookmarks[tt.id]
+		if got := b.String("title"); got != tt.title {
+			t.Errorf("moz_bookmarks[id=%d].title = %q, want %q", tt.id, got, tt.title)
+		}
+		if got := b.Int64("fk"); got != tt.fk {
+			t.Errorf("moz_bookmarks[id=%d].fk = %d, want %d", tt.id, got, tt.fk)
+		}
+		if got := b.Int64("parent"); got != tt.parent {
+			t.Errorf("moz_bookmarks[id=%d].parent = %d, want %d", tt.id, got, tt.parent)
+		}
+		if got := b.String("guid"); got != tt.guid {
+			t.Errorf("moz_bookmarks[id=%d].guid = %q, want %q", tt.id, got, tt.guid)
+		}
+	}
+
+	// syncStatus was added after the first rows were written.
+	if v, ok := bookmarks[7]["syncStatus"]; !ok || v != nil {
+		t.Errorf("moz_bookmarks[id=7].syncStatus = %v, want nil", v)
+	}
+	if got, want := bookmarks[11].Int64("syncStatus"), int64(2); got != want {
+		t.Errorf("moz_bookmarks[id=11].syncStatus = %d, want %d", got, want)
+	}
+
+	if _, err := db.Table("moz_nonexistent"); err == nil {
+		t.Errorf("Table(moz_nonexistent) unexpectedly succeeded")
+	}
+}
+
+func readWAL(t *testing.T) (data, wal []byte) {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "wal", "places.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal, err = ioutil.ReadFile(filepath.Join("testdata", "wal", "places.sqlite-wal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, wal
+}
+
+func TestWAL(t *testing.T) {
+	db, err := Open(filepath.Join("testdata", "wal", "places.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bookmarks := bookmarksByID(t, db)
+	if got, want := bookmarks[6].String("title"), "kint (renamed)"; got != want {
+		t.Errorf("moz_bookmarks[id=6].title = %q, want %q", got, want)
+	}
+	if got, want := bookmarks[12].String("title"), "Page 3"; got != want {
+		t.Errorf("moz_bookmarks[id=12].title = %q, want %q", got, want)
+	}
+	if _, ok := bookmarks[9]; ok {
+		t.Errorf("moz_bookmarks[id=9] not deleted")
+	}
+
+	// Without the write-ahead log, the database file has the old contents.
+	data, _ := readWAL(t)
+	db, err = Parse(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bookmarks = bookmarksByID(t, db)
+	if got, want := bookmarks[6].String("title"), "kint"; got != want {
+		t.Errorf("without WAL: moz_bookmarks[id=6].title = %q, want %q", got, want)
+	}
+	if _, ok := bookmarks[12]; ok {
+		t.Errorf("without WAL: moz_bookmarks[id=12] unexpectedly present")
+	}
+}
+
+func TestWALIncompleteFrame(t *testing.T) {
+	data, wal := readWAL(t)
+	pageSize := 1024
+	frameSize := 24 + pageSize
+	if (len(wal)-32)%frameSize != 0 || len(wal) < 32+2*frameSize {
+		t.Fatalf("unexpected write-ahead log size %d", len(wal))
+	}
+
+	// Each statement of places-wal.sql is a transaction of one frame. Dropping
+	// the last frame reverts the DELETE.
+	truncated := wal[:len(wal)-frameSize]
+	db, err := Parse(data, truncated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bookmarks := bookmarksByID(t, db)
+	if _, ok := bookmarks[9]; !ok {
+		t.Errorf("truncated WAL: moz_bookmarks[id=9] missing")
+	}
+	if _, ok := bookmarks[12]; !ok {
+		t.Errorf("truncated WAL: moz_bookmarks[id=12] missing")
+	}
+
+	// A frame with an invalid checksum (e.g. partially written) ends the log.
+	corrupt := append([]byte(nil), wal...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	db, err = Parse(data, corrupt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bookmarksByID(t, db)[9]; !ok {
+		t.Errorf("corrupt WAL frame: moz_bookmarks[id=9] missing")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	data, wal := readWAL(t)
+	for _, tt := range []struct {
+		desc      string
+		data, wal []byte
+	}{
+		{desc: "empty", data: nil},
+		{desc: "not a database", data: []byte(strings.Repeat("x", 1024))},
+		{desc: "invalid page size", data: append(append([]byte(nil), data[:16]...), append([]byte{0x03, 0x00}, data[18:]...)...)},
+		{desc: "invalid WAL magic", data: data, wal: append([]byte("nope"), wal[4:]...)},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if _, err := Parse(tt.data, tt.wal); err == nil {
+				t.Errorf("Parse unexpectedly succeeded")
+			}
+		})
+	}
+}
+
+func TestDecodeRecord(t *testing.T) {
+	got, err := decodeRecord([]byte{
+		6,            // header size
+		0,            // NULL
+		1,            // 8-bit integer
+		8,            // 0
+		9,            // 1
+		12 + 2*3 + 1, // 3 byte text
+		0xff,         // body: -1
+		'a', 'b', 'c',
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{nil, int64(-1), int64(0), int64(1), "abc"}
+	if len(got) != len(want) {
+		t.Fatalf("decodeRecord = %v, want %v", got, want)
+	}
+	for idx := range want {
+		if got[idx] != want[idx] {
+			t.Errorf("decodeRecord[%d] = %#v, want %#v", idx, got[idx], want[idx])
+		}
+	}
+}
+
+func TestDecodeRecordCorrupt(t *testing.T) {
+	for _, tt := range []struct {
+		desc    string
+		payload []byte
+	}{
+		{desc: "empty", payload: nil},
+		{desc: "header size 0", payload: []byte{0, 1, 2}},
+		{desc: "header size exceeds payload", payload: []byte{5, 1}},
+		{desc: "huge header size", payload: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{desc: "truncated body", payload: []byte{2, 6, 1, 2, 3}},
+		{desc: "reserved serial type", payload: []byte{2, 10}},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if _, err := decodeRecord(tt.payload); err == nil {
+				t.Errorf("decodeRecord(%v) unexpectedly succeeded", tt.payload)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+-- Changes committed to the write-ahead log of wal/places.sqlite, see
+-- sqlite_test.go.
+UPDATE moz_bookmarks SET title = 'kint (renamed)' WHERE id = 6;
+INSERT INTO moz_bookmarks (id, type, fk, parent, position, title, guid) VALUES
+  (12, 1, 3, 6, 4, 'Page 3', 'bookmark0005');
+DELETE FROM moz_bookmarks WHERE id = 9;
@@ -0,0 +1,30 @@
+-- Schema excerpt of Firefox’s places.sqlite. The database uses small pages, so
+-- that moz_places spans interior b-tree pages and its long URL overflow pages.
+PRAGMA page_size = 1024;
+CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR, rev_host LONGVARCHAR, visit_count INTEGER DEFAULT 0, hidden INTEGER DEFAULT 0 NOT NULL, typed INTEGER DEFAULT 0 NOT NULL, frecency INTEGER DEFAULT -1 NOT NULL, last_visit_date INTEGER , guid TEXT, foreign_count INTEGER DEFAULT 0 NOT NULL, url_hash INTEGER DEFAULT 0 NOT NULL );
+CREATE TABLE moz_bookmarks (id INTEGER PRIMARY KEY, type INTEGER, fk INTEGER DEFAULT NULL, parent INTEGER, position INTEGER, title LONGVARCHAR, keyword_id INTEGER, folder_type TEXT, dateAdded INTEGER, lastModified INTEGER, guid TEXT);
+CREATE UNIQUE INDEX moz_places_url_hashindex ON moz_places (url_hash, id);
+
+WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 100)
+INSERT INTO moz_places (id, url, title, rev_host, frecency, last_visit_date, guid, url_hash)
+SELECT i, 'https://example.com/page/' || i, 'Page ' || i, 'moc.elpmaxe.', i * 100, 1600000000000000 + i, printf('place%07d', i), -i * 1000003
+FROM n;
+INSERT INTO moz_places (id, url, title, guid) VALUES (101, 'https://example.com/long?q=' || replace(printf('%.3000c', 'x'), ' ', 'x'), 'Long', 'placelong01');
+INSERT INTO moz_places (id, url, title, guid) VALUES (102, 'https://example.com/unicode', 'Grüße – ♫', 'placeuni001');
+
+INSERT INTO moz_bookmarks (id, type, fk, parent, position, title, guid) VALUES
+  (1, 2, NULL, 0, 0, '', 'root________'),
+  (2, 2, NULL, 1, 0, 'menu', 'menu________'),
+  (3, 2, NULL, 1, 1, 'toolbar', 'toolbar_____'),
+  (4, 2, NULL, 1, 3, 'unfiled', 'unfiled_____'),
+  (5, 2, NULL, 1, 4, 'mobile', 'mobile______'),
+  (6, 2, NULL, 3, 0, 'kint', 'kintfolder01'),
+  (7, 1, 1, 6, 0, 'Page 1', 'bookmark0001'),
+  (8, 1, 101, 6, 1, 'Long', 'bookmark0002'),
+  (9, 3, NULL, 6, 2, NULL, 'separator001'),
+  (10, 1, 102, 3, 1, 'Grüße – ♫', 'bookmark0003');
+
+-- A column added after rows were written, like Firefox’s migrations do.
+ALTER TABLE moz_bookmarks ADD COLUMN syncStatus INTEGER NOT NULL DEFAULT 0;
+INSERT INTO moz_bookmarks (id, type, fk, parent, position, title, guid, syncStatus) VALUES
+  (11, 1, 2, 6, 3, 'Page 2', 'bookmark0004', 2);
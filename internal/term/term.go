@@ -0,0 +1,57 @@
+// Package term switches Linux terminals into raw mode and queries their size,
+// which is all wsmgr tui needs (no cgo, no terminfo).
+package term
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func ioctl(fd int, req uint, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// State is a terminal state, which can be restored via Restore.
+type State struct {
+	termios syscall.Termios
+}
+
+// MakeRaw puts the terminal connected to fd into raw mode (like cfmakeraw(3))
+// and returns the previous state.
+func MakeRaw(fd int) (*State, error) {
+	var old State
+	if err := ioctl(fd, syscall.TCGETS, unsafe.Pointer(&old.termios)); err != nil {
+		return nil, err
+	}
+	raw := old.termios
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, syscall.TCSETS, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return &old, nil
+}
+
+// Restore restores the terminal connected to fd to state.
+func Restore(fd int, state *State) error {
+	return ioctl(fd, syscall.TCSETS, unsafe.Pointer(&state.termios))
+}
+
+// Size returns the number of columns and rows of the terminal connected to fd.
+func Size(fd int) (cols, rows int, _ error) {
+	var ws struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return 0, 0, err
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
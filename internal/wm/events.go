@@ -0,0 +1,109 @@
+package wm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"go.i3wm.org/i3/v4"
+)
+
+// WindowEvent is an i3 window event, including the Props of its container
+// (which go.i3wm.org/i3 does not decode).
+type WindowEvent struct {
+	Change    string
+	Container i3.Node
+	Props     Props
+}
+
+// Class returns the X11 window class or, for Wayland windows, the app_id of the
+// window of ev (see Class).
+func (ev *WindowEvent) Class() string {
+	return Class(&ev.Container, map[i3.NodeID]Props{ev.Container.ID: ev.Props})
+}
+
+// WindowEvents receives window events, see SubscribeWindowEvents.
+type WindowEvents struct {
+	conn net.Conn
+	ev   *WindowEvent
+	err  error
+}
+
+// SubscribeWindowEvents subscribes to window events. Unlike i3.Subscribe, which
+// only connects on the first call of Next, it returns once the subscription is
+// active, so that no window created afterwards is missed.
+func SubscribeWindowEvents() (*WindowEvents, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	const subscribe = 2 // message type, see i3 IPC documentation
+	if err := writeMessage(conn, subscribe, []byte(`["window"]`)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	typ, payload, err := readMessage(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var reply struct {
+		Success bool `json:"success"`
+	}
+	if err := json.Unmarshal(payload, &reply); err != nil || typ != subscribe || !reply.Success {
+		conn.Close()
+		return nil, fmt.Errorf("subscribing to window events failed: %s", payload)
+	}
+	return &WindowEvents{conn: conn}, nil
+}
+
+// Next waits for the next window event. It returns false once Close was
+// called or an error occurred (see Err).
+func (w *WindowEvents) Next() bool {
+	const windowEvent = 1<<31 | 3 // event type, see i3 IPC documentation
+	for {
+		typ, payload, err := readMessage(w.conn)
+		if err != nil {
+			w.err = err
+			return false
+		}
+		if typ != windowEvent {
+			continue
+		}
+		var raw struct {
+			Change    string          `json:"change"`
+			Container json.RawMessage `json:"container"`
+		}
+		if err := json.Unmarshal(payload, &raw); err != nil {
+			w.err = err
+			return false
+		}
+		ev := &WindowEvent{Change: raw.Change}
+		if err := json.Unmarshal(raw.Container, &ev.Container); err != nil {
+			w.err = err
+			return false
+		}
+		if err := json.Unmarshal(raw.Container, &ev.Props); err != nil {
+			w.err = err
+			return false
+		}
+		w.ev = ev
+		return true
+	}
+}
+
+// Event returns the event received by the last call of Next.
+func (w *WindowEvents) Event() *WindowEvent {
+	return w.ev
+}
+
+// Err returns the error which made Next return false. After Close, Err returns
+// an error, too.
+func (w *WindowEvents) Err() error {
+	return w.err
+}
+
+// Close ends the subscription, making a blocked Next return false.
+func (w *WindowEvents) Close() error {
+	return w.conn.Close()
+}
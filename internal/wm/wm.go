@@ -0,0 +1,316 @@
+// Package wm abstracts over the differences between i3 and sway, which
+// implements the i3 IPC protocol, so that go.i3wm.org/i3 can be used for both.
+//
+// Differences handled by this package:
+//
+//   - go.i3wm.org/i3 finds the IPC socket via i3 --get-socketpath. Init uses
+//     the SWAYSOCK environment variable instead when running under sway.
+//   - Wayland windows have no X11 window id (i3.Node.Window is 0) and no window
+//     properties. Instead, sway’s GET_TREE reply contains their app_id.
+//   - sway’s GET_TREE reply (and window events) contain the process id of each
+//     window, whereas on i3, the _NET_WM_PID property of the X11 window needs
+//     to be read.
+//   - Commands whose criteria match no window succeed on i3, but fail on sway
+//     (“No matching node.”). RunCommand treats them as successful on both.
+//   - i3 (since 4.20) reports the contents of included config files in its
+//     GET_CONFIG reply, sway does not. Config reads them from disk instead.
+//
+// Windows should be referred to in commands via [con_id=…] (not [id=…], which
+// only matches X11 windows).
+package wm
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/stapelberg/wsmgr-for-i3/internal/xwin"
+	"go.i3wm.org/i3/v4"
+)
+
+// Sway returns whether the window manager is sway.
+func Sway() bool {
+	return os.Getenv("SWAYSOCK") != ""
+}
+
+// Init configures go.i3wm.org/i3 to connect to sway when running under sway.
+// It must be called before any other go.i3wm.org/i3 function.
+func Init() {
+	sock := os.Getenv("SWAYSOCK")
+	if sock == "" {
+		return // i3
+	}
+	i3.SocketPathHook = func() (string, error) {
+		return sock, nil
+	}
+	i3.IsRunningHook = func() bool {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+}
+
+// IsWindow returns whether n is a window (as opposed to e.g. a split
+// container or a workspace).
+func IsWindow(n *i3.Node) bool {
+	if n.Window != 0 {
+		return true // X11 window (on sway: Xwayland)
+	}
+	if !Sway() {
+		return false
+	}
+	// sway removes empty split containers, so leaf containers are windows.
+	return (n.Type == i3.Con || n.Type == i3.FloatingCon) &&
+		len(n.Nodes) == 0 &&
+		len(n.FloatingNodes) == 0
+}
+
+// Props are the properties of a node which go.i3wm.org/i3 does not decode.
+type Props struct {
+	AppID string `json:"app_id"` // Wayland application id
+	PID   int    `json:"pid"`
+}
+
+type propsNode struct {
+	Props
+	ID            i3.NodeID    `json:"id"`
+	Nodes         []*propsNode `json:"nodes"`
+	FloatingNodes []*propsNode `json:"floating_nodes"`
+}
+
+// Properties returns the sway-specific properties of all nodes by node id. On
+// i3, it returns nil.
+func Properties() (map[i3.NodeID]Props, error) {
+	if !Sway() {
+		return nil, nil
+	}
+	const getTree = 4 // message type, see i3 IPC documentation
+	b, err := roundTrip(getTree, nil)
+	if err != nil {
+		return nil, err
+	}
+	var root propsNode
+	if err := json.Unmarshal(b, &root); err != nil {
+		return nil, err
+	}
+	props := make(map[i3.NodeID]Props)
+	var walk func(n *propsNode)
+	walk = func(n *propsNode) {
+		props[n.ID] = n.Props
+		for _, c := range n.Nodes {
+			walk(c)
+		}
+		for _, c := range n.FloatingNodes {
+			walk(c)
+		}
+	}
+	walk(&root)
+	return props, nil
+}
+
+// Class returns the X11 window class of n or, for Wayland windows, its app_id
+// (looked up in props, see Properties). X11 classes and app_ids of the same
+// program usually only differ in case, e.g. Google-chrome and google-chrome.
+func Class(n *i3.Node, props map[i3.NodeID]Props) string {
+	if n.WindowProperties.Class != "" {
+		return n.WindowProperties.Class
+	}
+	return props[n.ID].AppID
+}
+
+// RunCommand runs command like i3.RunCommand, but (like i3) treats commands
+// whose criteria match no window as successful on sway, e.g. when moving a
+// window which was closed in the meantime.
+func RunCommand(command string) ([]i3.CommandResult, error) {
+	results, err := i3.RunCommand(command)
+	if err == nil || len(results) == 0 {
+		return results, err
+	}
+	for _, r := range results {
+		if !r.Success && !strings.HasPrefix(r.Error, "No matching node") {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+type configReply struct {
+	Config          string `json:"config"`
+	IncludedConfigs []struct {
+		RawContents string `json:"raw_contents"`
+	} `json:"included_configs"`
+}
+
+// Config returns the loaded config, followed by the contents of the config
+// files it includes (so that variables set in the main config apply to them).
+//
+// When the window manager does not report included config files, Config reads
+// the files of include lines with absolute (or ~/) paths. Relative paths and
+// variables in include lines are not supported in that case.
+func Config() (string, error) {
+	const getConfig = 9 // message type, see i3 IPC documentation
+	b, err := roundTrip(getConfig, nil)
+	if err != nil {
+		return "", err
+	}
+	var reply configReply
+	if err := json.Unmarshal(b, &reply); err != nil {
+		return "", err
+	}
+	contents := []string{reply.Config}
+	if len(reply.IncludedConfigs) > 0 {
+		for _, ic := range reply.IncludedConfigs {
+			contents = append(contents, ic.RawContents)
+		}
+		return strings.Join(contents, "\n"), nil
+	}
+	for _, line := range strings.Split(reply.Config, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "include" {
+			continue
+		}
+		pattern := strings.Trim(fields[1], `"`)
+		if strings.HasPrefix(pattern, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				continue
+			}
+			pattern = filepath.Join(home, pattern[len("~/"):])
+		}
+		if !filepath.IsAbs(pattern) {
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		for _, fn := range matches {
+			b, err := ioutil.ReadFile(fn)
+			if err != nil {
+				continue // like i3, which skips unreadable files
+			}
+			contents = append(contents, string(b))
+		}
+	}
+	return strings.Join(contents, "\n"), nil
+}
+
+// dial connects to the IPC socket of the window manager.
+func dial() (net.Conn, error) {
+	path, err := i3.SocketPathHook()
+	if err != nil {
+		return nil, err
+	}
+	return net.Dial("unix", strings.TrimSpace(path))
+}
+
+// The IPC protocol uses the native byte order, i.e. little endian on all
+// platforms wsmgr-for-i3 is used on.
+const magic = "i3-ipc"
+
+func writeMessage(w io.Writer, typ uint32, payload []byte) error {
+	msg := make([]byte, len(magic)+8, len(magic)+8+len(payload))
+	copy(msg, magic)
+	binary.LittleEndian.PutUint32(msg[len(magic):], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(msg[len(magic)+4:], typ)
+	_, err := w.Write(append(msg, payload...))
+	return err
+}
+
+func readMessage(r io.Reader) (typ uint32, payload []byte, _ error) {
+	header := make([]byte, len(magic)+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	if string(header[:len(magic)]) != magic {
+		return 0, nil, fmt.Errorf("invalid IPC reply magic %q", header[:len(magic)])
+	}
+	payload = make([]byte, binary.LittleEndian.Uint32(header[len(magic):]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return binary.LittleEndian.Uint32(header[len(magic)+4:]), payload, nil
+}
+
+// roundTrip sends an IPC message to the window manager and returns the reply
+// payload.
+func roundTrip(typ uint32, payload []byte) ([]byte, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := writeMessage(conn, typ, payload); err != nil {
+		return nil, err
+	}
+	got, reply, err := readMessage(conn)
+	if err != nil {
+		return nil, err
+	}
+	if got != typ {
+		return nil, fmt.Errorf("unexpected IPC reply type: got %d, want %d", got, typ)
+	}
+	return reply, nil
+}
+
+// PIDReader determines the process which created a window.
+type PIDReader struct {
+	xc *xgb.Conn // nil on sway
+}
+
+// NewPIDReader returns a PIDReader, which needs to be closed after use.
+func NewPIDReader() (*PIDReader, error) {
+	if Sway() {
+		return &PIDReader{}, nil
+	}
+	xc, err := xgb.NewConn()
+	if err != nil {
+		return nil, err
+	}
+	return &PIDReader{xc: xc}, nil
+}
+
+// PID returns the process id of the process which created window n.
+func (r *PIDReader) PID(n *i3.Node) (int, error) {
+	if r.xc != nil {
+		return xwin.PID(r.xc, n.Window)
+	}
+	props, err := Properties()
+	if err != nil {
+		return 0, err
+	}
+	return pid(n, props[n.ID])
+}
+
+// EventPID returns the process id of the process which created the window of
+// ev. Unlike PID, it does not need to request the tree on sway.
+func (r *PIDReader) EventPID(ev *WindowEvent) (int, error) {
+	if r.xc != nil {
+		return xwin.PID(r.xc, ev.Container.Window)
+	}
+	return pid(&ev.Container, ev.Props)
+}
+
+func pid(n *i3.Node, props Props) (int, error) {
+	if props.PID != 0 {
+		return props.PID, nil
+	}
+	return 0, fmt.Errorf("no pid for node %d", n.ID)
+}
+
+// Close closes the X11 connection (if any).
+func (r *PIDReader) Close() {
+	if r.xc != nil {
+		r.xc.Close()
+	}
+}
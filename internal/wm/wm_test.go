@@ -0,0 +1,153 @@
+package wm_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stapelberg/wsmgr-for-i3/internal/wm"
+	"github.com/stapelberg/wsmgr-for-i3/internal/wm/wmtest"
+	"go.i3wm.org/i3/v4"
+)
+
+func TestWindowEvents(t *testing.T) {
+	s := wmtest.NewServer(t)
+	s.Install()
+	s.AddWorkspace("1: kint")
+
+	recv, err := wm.SubscribeWindowEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recv.Close()
+	// SubscribeWindowEvents returns once subscribed, so this window cannot be
+	// missed.
+	w := s.NewWindow(wmtest.Window{Name: "~/src/kint", AppID: "foot", PID: 4242})
+	if !recv.Next() {
+		t.Fatalf("Next: %v", recv.Err())
+	}
+	ev := recv.Event()
+	if got, want := ev.Change, "new"; got != want {
+		t.Errorf("Change = %q, want %q", got, want)
+	}
+	if got, want := ev.Container.ID, i3.NodeID(w.ID); got != want {
+		t.Errorf("Container.ID = %d, want %d", got, want)
+	}
+	if got, want := ev.Class(), "foot"; got != want {
+		t.Errorf("Class() = %q, want %q", got, want)
+	}
+
+	pids, err := wm.NewPIDReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pids.Close()
+	pid, err := pids.EventPID(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid != 4242 {
+		t.Errorf("EventPID = %d, want 4242", pid)
+	}
+
+	// PID reads the process id from the tree instead.
+	tree, err := i3.GetTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := tree.Root.FindChild(func(n *i3.Node) bool { return n.ID == i3.NodeID(w.ID) })
+	if n == nil {
+		t.Fatalf("window %d not found in tree", w.ID)
+	}
+	if !wm.IsWindow(n) {
+		t.Errorf("IsWindow(%+v) = false, want true", n)
+	}
+	pid, err = pids.PID(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid != 4242 {
+		t.Errorf("PID = %d, want 4242", pid)
+	}
+	props, err := wm.Properties()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := wm.Class(n, props), "foot"; got != want {
+		t.Errorf("Class = %q, want %q", got, want)
+	}
+
+	s.CloseWindow(w.ID)
+	if !recv.Next() {
+		t.Fatalf("Next: %v", recv.Err())
+	}
+	if got, want := recv.Event().Change, "close"; got != want {
+		t.Errorf("Change = %q, want %q", got, want)
+	}
+
+	recv.Close()
+	if recv.Next() {
+		t.Errorf("Next returned true after Close")
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	s := wmtest.NewServer(t)
+	s.Install()
+	s.AddWorkspace("1: kint")
+
+	// sway fails commands whose criteria match no window, i3 does not.
+	const closed = `[con_id=9999] move container to workspace "1: kint"`
+	if _, err := i3.RunCommand(closed); err == nil {
+		t.Fatalf("i3.RunCommand(%q) unexpectedly succeeded, fake does not answer like sway", closed)
+	}
+	if _, err := wm.RunCommand(closed); err != nil {
+		t.Errorf("wm.RunCommand(%q) = %v, want nil", closed, err)
+	}
+
+	for _, cmd := range []string{
+		`rename workspace "2: nonexistent" to "3: other"`,
+		`no such command`,
+		closed + `; no such command`,
+	} {
+		if _, err := wm.RunCommand(cmd); err == nil {
+			t.Errorf("wm.RunCommand(%q) unexpectedly succeeded", cmd)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	s := wmtest.NewServer(t)
+	s.Install()
+
+	// i3 reports included config files.
+	s.SetConfig("set $ext HDMI-1\ninclude outputs.conf",
+		wmtest.IncludedConfig{Path: "/home/michael/.config/i3/outputs.conf", RawContent: "workspace 11 output $ext"})
+	got, err := wm.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "set $ext HDMI-1\ninclude outputs.conf\nworkspace 11 output $ext"; got != want {
+		t.Errorf("Config = %q, want %q", got, want)
+	}
+
+	// sway does not: Config reads them.
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.conf": "workspace 1 output eDP-1",
+		"b.conf": "workspace 11 output HDMI-1",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := "include " + filepath.Join(dir, "*.conf") + "\ninclude relative.conf\ninclude /nonexistent"
+	s.SetConfig(config)
+	got, err = wm.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := config + "\nworkspace 1 output eDP-1\nworkspace 11 output HDMI-1"; got != want {
+		t.Errorf("Config = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,604 @@
+// Package wmtest provides a fake window manager for tests, which answers IPC
+// requests on a unix socket the way sway does.
+//
+// Only what wsmgr-for-i3 uses is implemented: GET_WORKSPACES, GET_TREE (with
+// app_id and pid of windows), GET_CONFIG, SUBSCRIBE (window events) and
+// RUN_COMMAND with the following commands:
+//
+//	workspace "<name>"
+//	rename workspace "<old>" to "<new>"
+//	[con_id=<id>] move container to workspace "<name>"
+//	[con_id=<id>] mark --add "<mark>"
+//
+// Like sway, the fake removes empty workspaces when switching away from them,
+// and fails commands whose criteria match no window (“No matching node.”).
+package wmtest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stapelberg/wsmgr-for-i3/internal/wm"
+	"go.i3wm.org/i3/v4"
+)
+
+// Window is a Wayland window.
+type Window struct {
+	ID    int64
+	Name  string // title
+	AppID string
+	PID   int
+	Marks []string
+}
+
+// Workspace is a workspace of the fake window manager.
+type Workspace struct {
+	ID      int64
+	Name    string
+	Output  string
+	Windows []Window
+}
+
+// Num returns the number of the workspace, or -1 if it has none.
+func (ws *Workspace) Num() int64 {
+	return workspaceNum(ws.Name)
+}
+
+// IncludedConfig is a config file included by the main config file.
+type IncludedConfig struct {
+	Path       string `json:"path"`
+	RawContent string `json:"raw_contents"`
+}
+
+// Server is a fake window manager, see the package documentation.
+type Server struct {
+	t    *testing.T
+	path string
+	ln   net.Listener
+
+	mu          sync.Mutex
+	nextID      int64
+	workspaces  []*Workspace
+	focused     string // name of the focused workspace
+	commands    []string
+	config      string
+	included    []IncludedConfig
+	subscribers []net.Conn
+}
+
+// NewServer starts a fake window manager, which is stopped when the test ends.
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sway-ipc.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{t: t, path: path, ln: ln, nextID: 100}
+	t.Cleanup(s.close)
+	go s.serve()
+	return s
+}
+
+// Install configures wm (and go.i3wm.org/i3) to use s as sway, until the test
+// ends.
+func (s *Server) Install() {
+	old, ok := os.LookupEnv("SWAYSOCK")
+	oldSocketPath, oldIsRunning := i3.SocketPathHook, i3.IsRunningHook
+	os.Setenv("SWAYSOCK", s.path)
+	wm.Init()
+	s.t.Cleanup(func() {
+		if ok {
+			os.Setenv("SWAYSOCK", old)
+		} else {
+			os.Unsetenv("SWAYSOCK")
+		}
+		i3.SocketPathHook, i3.IsRunningHook = oldSocketPath, oldIsRunning
+	})
+}
+
+func (s *Server) close() {
+	s.ln.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.subscribers {
+		conn.Close()
+	}
+}
+
+func (s *Server) id() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+// AddWorkspace adds a workspace with the specified windows on output eDP-1,
+// assigning ids to the workspace and windows. The first workspace is focused.
+func (s *Server) AddWorkspace(name string, windows ...Window) *Workspace {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ws := &Workspace{ID: s.id(), Name: name, Output: "eDP-1"}
+	for _, w := range windows {
+		w.ID = s.id()
+		ws.Windows = append(ws.Windows, w)
+	}
+	s.workspaces = append(s.workspaces, ws)
+	if s.focused == "" {
+		s.focused = name
+	}
+	return ws
+}
+
+// SetConfig sets the contents of the GET_CONFIG reply.
+func (s *Server) SetConfig(config string, included ...IncludedConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+	s.included = included
+}
+
+// Focus switches to workspace name, like the workspace command.
+func (s *Server) Focus(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.switchTo(name)
+}
+
+// Rename renames workspace oldName, like the rename workspace command.
+func (s *Server) Rename(oldName, newName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.rename(oldName, newName); err != nil {
+		s.t.Error(err)
+	}
+}
+
+// Workspaces returns a copy of the workspaces.
+func (s *Server) Workspaces() []Workspace {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]Workspace, len(s.workspaces))
+	for idx, ws := range s.workspaces {
+		result[idx] = *ws
+		result[idx].Windows = append([]Window(nil), ws.Windows...)
+	}
+	return result
+}
+
+// Commands returns the commands received via RUN_COMMAND so far (split at
+// semicolons).
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// NewWindow opens window w (assigning its id) on the focused workspace and
+// sends a window event to subscribers.
+func (s *Server) NewWindow(w Window) Window {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.ID = s.id()
+	ws := s.workspace(s.focused)
+	ws.Windows = append(ws.Windows, w)
+	s.sendWindowEvent("new", w)
+	return w
+}
+
+// CloseWindow closes the window with the specified id.
+func (s *Server) CloseWindow(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ws, idx := s.window(id)
+	if ws == nil {
+		s.t.Errorf("CloseWindow: no window with id %d", id)
+		return
+	}
+	w := ws.Windows[idx]
+	ws.Windows = append(ws.Windows[:idx], ws.Windows[idx+1:]...)
+	s.sendWindowEvent("close", w)
+}
+
+func (s *Server) workspace(name string) *Workspace {
+	for _, ws := range s.workspaces {
+		if ws.Name == name {
+			return ws
+		}
+	}
+	return nil
+}
+
+func (s *Server) window(id int64) (*Workspace, int) {
+	for _, ws := range s.workspaces {
+		for idx, w := range ws.Windows {
+			if w.ID == id {
+				return ws, idx
+			}
+		}
+	}
+	return nil, -1
+}
+
+// workspaceNum returns the number of a workspace called name, like sway: the
+// leading digits, or -1.
+func workspaceNum(name string) int64 {
+	end := 0
+	for end < len(name) && name[end] >= '0' && name[end] <= '9' {
+		end++
+	}
+	num, err := strconv.ParseInt(name[:end], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return num
+}
+
+// ensure returns workspace name, creating it if it does not exist.
+func (s *Server) ensure(name string) *Workspace {
+	if ws := s.workspace(name); ws != nil {
+		return ws
+	}
+	ws := &Workspace{ID: s.id(), Name: name, Output: "eDP-1"}
+	s.workspaces = append(s.workspaces, ws)
+	return ws
+}
+
+func (s *Server) switchTo(name string) {
+	s.ensure(name)
+	if s.focused == name {
+		return
+	}
+	// Empty workspaces are removed when switching away from them.
+	if prev := s.workspace(s.focused); prev != nil && len(prev.Windows) == 0 {
+		s.remove(prev)
+	}
+	s.focused = name
+}
+
+func (s *Server) remove(ws *Workspace) {
+	for idx, w := range s.workspaces {
+		if w == ws {
+			s.workspaces = append(s.workspaces[:idx], s.workspaces[idx+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Server) rename(oldName, newName string) error {
+	ws := s.workspace(oldName)
+	if ws == nil {
+		return fmt.Errorf("There is no workspace with that name")
+	}
+	if other := s.workspace(newName); other != nil && other != ws {
+		return fmt.Errorf("Workspace already exists")
+	}
+	if s.focused == oldName {
+		s.focused = newName
+	}
+	ws.Name = newName
+	return nil
+}
+
+var (
+	workspaceRe = regexp.MustCompile(`^workspace "((?:[^"\\]|\\.)*)"$`)
+	renameRe    = regexp.MustCompile(`^rename workspace "((?:[^"\\]|\\.)*)" to "((?:[^"\\]|\\.)*)"$`)
+	moveRe      = regexp.MustCompile(`^\[con_id=(\d+)\] move container to workspace "((?:[^"\\]|\\.)*)"$`)
+	markRe      = regexp.MustCompile(`^\[con_id=(\d+)\] mark --add "((?:[^"\\]|\\.)*)"$`)
+	unquoter    = strings.NewReplacer(`\"`, `"`, `\\`, `\`)
+)
+
+// splitCommands splits command at semicolons outside of quotes.
+func splitCommands(command string) []string {
+	var (
+		cmds   []string
+		quoted bool
+		start  int
+	)
+	for i := 0; i < len(command); i++ {
+		switch command[i] {
+		case '\\':
+			i++ // skip the escaped character
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				cmds = append(cmds, strings.TrimSpace(command[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(cmds, strings.TrimSpace(command[start:]))
+}
+
+type commandResult struct {
+	Success    bool   `json:"success"`
+	ParseError bool   `json:"parse_error,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (s *Server) runCommand(cmd string) commandResult {
+	s.commands = append(s.commands, cmd)
+	if m := workspaceRe.FindStringSubmatch(cmd); m != nil {
+		s.switchTo(unquoter.Replace(m[1]))
+		return commandResult{Success: true}
+	}
+	if m := renameRe.FindStringSubmatch(cmd); m != nil {
+		if err := s.rename(unquoter.Replace(m[1]), unquoter.Replace(m[2])); err != nil {
+			return commandResult{Error: err.Error()}
+		}
+		return commandResult{Success: true}
+	}
+	if m := moveRe.FindStringSubmatch(cmd); m != nil {
+		id, _ := strconv.ParseInt(m[1], 10, 64)
+		ws, idx := s.window(id)
+		if ws == nil {
+			return commandResult{Error: "No matching node."}
+		}
+		w := ws.Windows[idx]
+		ws.Windows = append(ws.Windows[:idx], ws.Windows[idx+1:]...)
+		target := s.ensure(unquoter.Replace(m[2]))
+		target.Windows = append(target.Windows, w)
+		return commandResult{Success: true}
+	}
+	if m := markRe.FindStringSubmatch(cmd); m != nil {
+		id, _ := strconv.ParseInt(m[1], 10, 64)
+		ws, idx := s.window(id)
+		if ws == nil {
+			return commandResult{Error: "No matching node."}
+		}
+		// Marks are unique: the mark is moved from other windows.
+		mark := unquoter.Replace(m[2])
+		for _, other := range s.workspaces {
+			for i, w := range other.Windows {
+				var marks []string
+				for _, m := range w.Marks {
+					if m != mark {
+						marks = append(marks, m)
+					}
+				}
+				other.Windows[i].Marks = marks
+			}
+		}
+		ws.Windows[idx].Marks = append(ws.Windows[idx].Marks, mark)
+		return commandResult{Success: true}
+	}
+	return commandResult{ParseError: true, Error: "Unknown/invalid command"}
+}
+
+type node struct {
+	ID            int64    `json:"id"`
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	Num           *int64   `json:"num,omitempty"`
+	Output        string   `json:"output,omitempty"`
+	AppID         *string  `json:"app_id,omitempty"`
+	PID           int      `json:"pid,omitempty"`
+	Window        *int64   `json:"window"`
+	Shell         string   `json:"shell,omitempty"`
+	Focused       bool     `json:"focused"`
+	Focus         []int64  `json:"focus"`
+	Marks         []string `json:"marks,omitempty"`
+	Nodes         []*node  `json:"nodes"`
+	FloatingNodes []*node  `json:"floating_nodes"`
+}
+
+func windowNode(w Window) *node {
+	appID := w.AppID
+	return &node{
+		ID:            w.ID,
+		Name:          w.Name,
+		Type:          "con",
+		AppID:         &appID,
+		PID:           w.PID,
+		Marks:         w.Marks,
+		Shell:         "xdg_shell",
+		Nodes:         []*node{},
+		FloatingNodes: []*node{},
+	}
+}
+
+func (s *Server) tree() *node {
+	root := &node{ID: 1, Name: "root", Type: "root"}
+	outputs := make(map[string]*node)
+	for _, ws := range s.workspaces {
+		out, ok := outputs[ws.Output]
+		if !ok {
+			out = &node{ID: s.id(), Name: ws.Output, Type: "output"}
+			outputs[ws.Output] = out
+			root.Nodes = append(root.Nodes, out)
+		}
+		num := ws.Num()
+		wsNode := &node{
+			ID:            ws.ID,
+			Name:          ws.Name,
+			Type:          "workspace",
+			Num:           &num,
+			Output:        ws.Output,
+			Nodes:         []*node{},
+			FloatingNodes: []*node{},
+		}
+		// The most recently opened window has the focus.
+		for i := len(ws.Windows) - 1; i >= 0; i-- {
+			wsNode.Focus = append(wsNode.Focus, ws.Windows[i].ID)
+		}
+		for i, w := range ws.Windows {
+			n := windowNode(w)
+			n.Focused = ws.Name == s.focused && i == len(ws.Windows)-1
+			wsNode.Nodes = append(wsNode.Nodes, n)
+		}
+		out.Nodes = append(out.Nodes, wsNode)
+		if ws.Name == s.focused {
+			out.Focus = append([]int64{ws.ID}, out.Focus...)
+			root.Focus = append([]int64{out.ID}, root.Focus...)
+		} else {
+			out.Focus = append(out.Focus, ws.ID)
+		}
+	}
+	for _, out := range root.Nodes {
+		if len(root.Focus) == 0 || root.Focus[0] != out.ID {
+			root.Focus = append(root.Focus, out.ID)
+		}
+	}
+	return root
+}
+
+type workspaceReply struct {
+	ID      int64   `json:"id"`
+	Num     int64   `json:"num"`
+	Name    string  `json:"name"`
+	Visible bool    `json:"visible"`
+	Focused bool    `json:"focused"`
+	Urgent  bool    `json:"urgent"`
+	Output  string  `json:"output"`
+	Rect    i3.Rect `json:"rect"`
+	Type    string  `json:"type"`
+}
+
+// IPC message types, see the i3 IPC documentation.
+const (
+	runCommand    = 0
+	getWorkspaces = 1
+	subscribe     = 2
+	getTree       = 4
+	getConfig     = 9
+	windowEvent   = 1<<31 | 3
+)
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return // listener closed
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	for {
+		typ, payload, err := readMessage(conn)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		reply, keep := s.reply(conn, typ, payload)
+		if err := writeMessage(conn, typ, reply); err != nil {
+			conn.Close()
+			return
+		}
+		if keep {
+			return // subscriber, events are sent by sendWindowEvent
+		}
+	}
+}
+
+func (s *Server) reply(conn net.Conn, typ uint32, payload []byte) (reply []byte, subscribed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var v interface{}
+	switch typ {
+	case runCommand:
+		var results []commandResult
+		for _, cmd := range splitCommands(string(payload)) {
+			results = append(results, s.runCommand(cmd))
+		}
+		v = results
+
+	case getWorkspaces:
+		workspaces := []workspaceReply{}
+		for _, ws := range s.workspaces {
+			workspaces = append(workspaces, workspaceReply{
+				ID:      ws.ID,
+				Num:     ws.Num(),
+				Name:    ws.Name,
+				Visible: ws.Name == s.focused,
+				Focused: ws.Name == s.focused,
+				Output:  ws.Output,
+				Type:    "workspace",
+			})
+		}
+		v = workspaces
+
+	case subscribe:
+		var events []string
+		if err := json.Unmarshal(payload, &events); err != nil || len(events) != 1 || events[0] != "window" {
+			v = map[string]interface{}{"success": false}
+			break
+		}
+		s.subscribers = append(s.subscribers, conn)
+		subscribed = true
+		v = map[string]interface{}{"success": true}
+
+	case getTree:
+		v = s.tree()
+
+	case getConfig:
+		v = map[string]interface{}{
+			"config":           s.config,
+			"included_configs": s.included,
+		}
+
+	default:
+		v = map[string]interface{}{"success": false, "error": "unsupported message type"}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		s.t.Error(err)
+	}
+	return b, subscribed
+}
+
+func (s *Server) sendWindowEvent(change string, w Window) {
+	b, err := json.Marshal(map[string]interface{}{
+		"change":    change,
+		"container": windowNode(w),
+	})
+	if err != nil {
+		s.t.Error(err)
+		return
+	}
+	subscribers := s.subscribers[:0]
+	for _, conn := range s.subscribers {
+		if err := writeMessage(conn, windowEvent, b); err != nil {
+			conn.Close()
+			continue // unsubscribed
+		}
+		subscribers = append(subscribers, conn)
+	}
+	s.subscribers = subscribers
+}
+
+const magic = "i3-ipc"
+
+func writeMessage(w io.Writer, typ uint32, payload []byte) error {
+	msg := make([]byte, len(magic)+8, len(magic)+8+len(payload))
+	copy(msg, magic)
+	binary.LittleEndian.PutUint32(msg[len(magic):], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(msg[len(magic)+4:], typ)
+	_, err := w.Write(append(msg, payload...))
+	return err
+}
+
+func readMessage(r io.Reader) (uint32, []byte, error) {
+	header := make([]byte, len(magic)+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	if string(header[:len(magic)]) != magic {
+		return 0, nil, fmt.Errorf("invalid magic %q", header[:len(magic)])
+	}
+	payload := make([]byte, binary.LittleEndian.Uint32(header[len(magic):]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return binary.LittleEndian.Uint32(header[len(magic)+4:]), payload, nil
+}
@@ -0,0 +1,307 @@
+// Package wsname formats and parses i3 workspace names according to a
+// configurable template, so that the number, icon and name of a workspace can
+// be recovered from its i3 name.
+//
+// Templates consist of text and the following placeholders:
+//
+//	{num}     workspace number (must be at the start, i3 reads the number from there)
+//	{icon}    workspace icon, a non-ASCII glyph (omitted with the adjacent white space if empty)
+//	{name}    workspace name, i.e. the name of its configuration directory
+//
+// When parsing, white space in the template matches any amount of white space,
+// so that e.g. 3:kint and 3: kint both match {num}: {name}. Names whose first
+// word (or last word, if {icon} follows {name}) is a glyph are ambiguous: in
+// 3: ★ stars, the glyph is parsed as the icon (unless resolved differently by
+// looking up the configured workspaces, see WithIcons).
+//
+// Workspaces can be colored via Pango markup, which i3bar renders when using a
+// pango: font. The markup follows the number, which i3 reads from the start of
+// the name, e.g. 3: <span foreground='#ff8800'>♫ music</span>.
+package wsname
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultTemplate is the template used unless configured otherwise.
+const DefaultTemplate = "{num}: {icon} {name}"
+
+// Parts are the parts of a workspace name.
+type Parts struct {
+	Num   int64  // -1 if the workspace has no number
+	Icon  string // empty if the workspace has no icon
+	Name  string // empty for numbered workspaces, e.g. 4
+	Color string // Pango color of icon and name, empty if not colored
+}
+
+// Icons are non-ASCII glyphs, so that words of the name (including punctuation,
+// e.g. in “! urgent”) are not mistaken for an icon.
+const iconExpr = `[^\x00-\x7f\s\p{L}\p{N}]+`
+
+var (
+	iconRe  = regexp.MustCompile(`^` + iconExpr + `$`)
+	colorRe = regexp.MustCompile(`^(#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|[a-zA-Z]+)$`)
+	spanRe  = regexp.MustCompile(`<span foreground=['"]([^'"]*)['"]>(.*)</span>`)
+)
+
+// ValidIcon returns whether icon can be used as Parts.Icon: a glyph (without
+// ASCII characters, letters, digits and white space).
+func ValidIcon(icon string) bool {
+	return iconRe.MatchString(icon)
+}
+
+// ValidColor returns whether color can be used as Parts.Color: #rgb, #rrggbb
+// or a color name, e.g. orange.
+func ValidColor(color string) bool {
+	return colorRe.MatchString(color)
+}
+
+type token struct {
+	placeholder string // num, icon or name; empty for text
+	text        string
+}
+
+// Format formats and parses workspace names.
+type Format struct {
+	tokens []token
+	res    []*regexp.Regexp // most specific first
+
+	// lookup resolves ambiguous names, see WithIcons.
+	lookup func(name string) (icon string, configured bool)
+}
+
+// New returns the Format for template.
+func New(template string) (*Format, error) {
+	var tokens []token
+	seen := make(map[string]bool)
+	for rest := template; rest != ""; {
+		start := strings.IndexByte(rest, '{')
+		if start == -1 {
+			tokens = append(tokens, token{text: rest})
+			break
+		}
+		if start > 0 {
+			tokens = append(tokens, token{text: rest[:start]})
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end == -1 {
+			return nil, fmt.Errorf("template %q: unterminated placeholder", template)
+		}
+		placeholder := rest[start+1 : start+end]
+		switch placeholder {
+		case "num", "icon", "name":
+		default:
+			return nil, fmt.Errorf("template %q: unknown placeholder {%s}", template, placeholder)
+		}
+		if seen[placeholder] {
+			return nil, fmt.Errorf("template %q: placeholder {%s} used more than once", template, placeholder)
+		}
+		seen[placeholder] = true
+		tokens = append(tokens, token{placeholder: placeholder})
+		rest = rest[start+end+1:]
+	}
+	if !seen["name"] {
+		return nil, fmt.Errorf("template %q: {name} placeholder missing", template)
+	}
+	if len(tokens) < 2 || tokens[0].placeholder != "num" || tokens[1].placeholder != "" {
+		return nil, fmt.Errorf("template %q: must start with {num}, followed by a separator", template)
+	}
+	f := &Format{tokens: tokens}
+	// Workspaces without number lack {num} and its separator.
+	for _, skip := range []int{0, 2} {
+		if seen["icon"] {
+			f.res = append(f.res, compile(tokens[skip:]))
+		}
+		f.res = append(f.res, compile(withoutIcon(tokens)[skip:]))
+	}
+	return f, nil
+}
+
+// Load returns the Format for the template in file fn, or for DefaultTemplate
+// if fn does not exist.
+func Load(fn string) (*Format, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return New(DefaultTemplate)
+		}
+		return nil, err
+	}
+	f, err := New(strings.TrimRight(string(b), "\n"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return f, nil
+}
+
+// WithIcons returns a copy of f which uses lookup (returning the icon of a
+// workspace, and whether it is configured at all) to resolve ambiguous names:
+// a glyph is only parsed as the icon if the workspace named by the rest of the
+// name has an icon, and the name including the glyph is not a configured
+// workspace. Otherwise, the glyph is part of the name, e.g. in 3: – notes.
+func (f *Format) WithIcons(lookup func(name string) (icon string, configured bool)) *Format {
+	c := *f
+	c.lookup = lookup
+	return &c
+}
+
+// withoutIcon returns tokens without the {icon} placeholder and the white space
+// separating it from the other placeholders.
+func withoutIcon(tokens []token) []token {
+	tokens = append([]token(nil), tokens...)
+	var result []token
+	for idx, t := range tokens {
+		if t.placeholder != "icon" {
+			result = append(result, t)
+			continue
+		}
+		if idx+1 < len(tokens) && tokens[idx+1].placeholder == "" &&
+			idx > 0 && tokens[idx-1].placeholder == "" {
+			// Remove the white space following the icon (but keep the text
+			// preceding the icon, which separates it from the number).
+			tokens[idx+1].text = strings.TrimLeft(tokens[idx+1].text, " ")
+		} else if idx == len(tokens)-1 && len(result) > 0 && result[len(result)-1].placeholder == "" {
+			// The icon is last, remove the white space preceding it.
+			result[len(result)-1].text = strings.TrimRight(result[len(result)-1].text, " ")
+		}
+	}
+	// Drop text tokens which became empty.
+	tokens = result[:0]
+	for _, t := range result {
+		if t.placeholder == "" && t.text == "" {
+			continue
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+func compile(tokens []token) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for idx, t := range tokens {
+		switch t.placeholder {
+		case "num":
+			expr.WriteString(`(?P<num>\d+)`)
+		case "icon":
+			expr.WriteString(`(?P<icon>` + iconExpr + `)`)
+		case "name":
+			expr.WriteString(`(?P<name>.+?)`)
+		default:
+			space := `\s*`
+			if (idx > 0 && tokens[idx-1].placeholder == "icon") ||
+				(idx+1 < len(tokens) && tokens[idx+1].placeholder == "icon") {
+				space = `\s+` // the icon must be separated from the name
+			}
+			for i, field := range strings.Split(t.text, " ") {
+				if i > 0 {
+					expr.WriteString(space)
+				}
+				expr.WriteString(regexp.QuoteMeta(field))
+			}
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+// Format returns the i3 workspace name for p.
+func (f *Format) Format(p Parts) string {
+	if p.Name == "" {
+		return strconv.FormatInt(p.Num, 10) // numbered workspace
+	}
+	tokens := f.tokens
+	if p.Icon == "" {
+		tokens = withoutIcon(tokens)
+	}
+	var prefix string // {num} and its separator
+	if p.Num >= 0 {
+		prefix = strconv.FormatInt(p.Num, 10) + tokens[1].text
+	}
+	escape := func(s string) string { return s }
+	if p.Color != "" {
+		escape = html.EscapeString
+	}
+	var b strings.Builder
+	for _, t := range tokens[2:] {
+		switch t.placeholder {
+		case "icon":
+			b.WriteString(escape(p.Icon))
+		case "name":
+			b.WriteString(escape(p.Name))
+		default:
+			b.WriteString(escape(t.text))
+		}
+	}
+	if p.Color == "" {
+		return prefix + b.String()
+	}
+	// Single quotes, because i3 commands use double quotes for names.
+	return prefix + "<span foreground='" + p.Color + "'>" + b.String() + "</span>"
+}
+
+// stripMarkup returns name without the Pango markup for its color, and the
+// color.
+func stripMarkup(name string) (plain, color string) {
+	m := spanRe.FindStringSubmatchIndex(name)
+	if m == nil {
+		return name, ""
+	}
+	return name[:m[0]] + html.UnescapeString(name[m[4]:m[5]]) + name[m[1]:], name[m[2]:m[3]]
+}
+
+// StripMarkup returns name without the Pango markup for its color, e.g. for
+// displaying it in a terminal.
+func StripMarkup(name string) string {
+	plain, _ := stripMarkup(name)
+	return plain
+}
+
+// Parse returns the parts of the i3 workspace name name. Names which do not
+// match the template are returned as Name, without number.
+func (f *Format) Parse(name string) Parts {
+	name, color := stripMarkup(name)
+	if num, err := strconv.ParseInt(name, 10, 64); err == nil && num >= 0 {
+		return Parts{Num: num} // numbered workspace
+	}
+	var withIcon *Parts // decided once the name without icon is parsed
+	for _, re := range f.res {
+		matches := re.FindStringSubmatch(name)
+		if matches == nil {
+			continue
+		}
+		p := Parts{Num: -1, Color: color}
+		for idx, group := range re.SubexpNames() {
+			switch group {
+			case "num":
+				p.Num, _ = strconv.ParseInt(matches[idx], 10, 64)
+			case "icon":
+				p.Icon = strings.TrimSpace(matches[idx])
+			case "name":
+				p.Name = strings.TrimSpace(matches[idx])
+			}
+		}
+		if p.Icon != "" && f.lookup != nil {
+			withIcon = &p
+			continue
+		}
+		if withIcon != nil {
+			if _, configured := f.lookup(p.Name); !configured {
+				if icon, _ := f.lookup(withIcon.Name); icon != "" {
+					return *withIcon
+				}
+			}
+		}
+		return p
+	}
+	if withIcon != nil {
+		return *withIcon
+	}
+	return Parts{Num: -1, Name: name, Color: color}
+}
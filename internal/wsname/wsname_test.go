@@ -0,0 +1,207 @@
+package wsname
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, template := range []string{
+		"{num}:{name}",
+		"{num}: {name}",
+		"{num}: {icon} {name}",
+		"{num} {name} {icon}",
+		"{num}:{icon}:{name}",
+	} {
+		f, err := New(template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []Parts{
+			{Num: 3, Name: "kint"},
+			{Num: 3, Name: "kint", Icon: "♫"},
+			{Num: 12, Name: "two words", Icon: ""},
+			{Num: 0, Name: "zero"},
+
+			// Names starting (or ending) with punctuation.
+			{Num: 4, Name: "-scratch"},
+			{Num: 4, Name: ".dotfiles", Icon: "⚙"},
+			{Num: 4, Name: "! urgent"},
+			{Num: 4, Name: "wip !"},
+			{Num: 4, Name: "[go] tip"},
+			{Num: 4, Name: ": colon"},
+
+			// Pango-wrapped names.
+			{Num: 5, Name: "music", Icon: "♫", Color: "#ff8800"},
+			{Num: 5, Name: "a & b <c>", Color: "orange"},
+			{Num: 5, Name: "it's", Icon: "★", Color: "#f80"},
+			{Num: -1, Name: "mail", Color: "blue"},
+
+			// Unnumbered names.
+			{Num: -1, Name: "kint"},
+			{Num: -1, Name: "kint", Icon: "♫"},
+			{Num: -1, Name: "! urgent"},
+
+			// Numbered workspaces.
+			{Num: 7},
+		} {
+			name := f.Format(p)
+			want := p
+			if !strings.Contains(template, "{icon}") {
+				want.Icon = "" // not part of the name
+			}
+			if got := f.Parse(name); got != want {
+				t.Errorf("template %q: Parse(Format(%+v)) = %+v (name %q), want %+v", template, p, got, name, want)
+			}
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, tt := range []struct {
+		template string
+		p        Parts
+		want     string
+	}{
+		{"{num}:{name}", Parts{Num: 3, Name: "kint"}, "3:kint"},
+		{"{num}: {icon} {name}", Parts{Num: 3, Name: "kint"}, "3: kint"},
+		{"{num}: {icon} {name}", Parts{Num: 3, Name: "music", Icon: "♫"}, "3: ♫ music"},
+		{"{num}: {icon} {name}", Parts{Num: -1, Name: "music", Icon: "♫"}, "♫ music"},
+		{"{num} {name} {icon}", Parts{Num: 3, Name: "kint"}, "3 kint"},
+		{"{num}: {icon} {name}", Parts{Num: 7}, "7"},
+		{
+			"{num}: {icon} {name}",
+			Parts{Num: 3, Name: "a & b", Icon: "♫", Color: "#ff8800"},
+			"3: <span foreground='#ff8800'>♫ a &amp; b</span>",
+		},
+	} {
+		f, err := New(tt.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.Format(tt.p); got != tt.want {
+			t.Errorf("template %q: Format(%+v) = %q, want %q", tt.template, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	f, err := New(DefaultTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		want Parts
+	}{
+		// White space in the template matches any amount of white space.
+		{"3:kint", Parts{Num: 3, Name: "kint"}},
+		{"3:   ♫   music", Parts{Num: 3, Name: "music", Icon: "♫"}},
+		// Names which do not match the template.
+		{"www", Parts{Num: -1, Name: "www"}},
+		{"3 www", Parts{Num: -1, Name: "3 www"}},
+		{"3", Parts{Num: 3}},
+		// Glyphs at the start of the name are parsed as the icon.
+		{"3: ★ stars", Parts{Num: 3, Name: "stars", Icon: "★"}},
+		// ASCII punctuation is not an icon.
+		{"3: * starred", Parts{Num: 3, Name: "* starred"}},
+	} {
+		if got := f.Parse(tt.name); got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWithIcons(t *testing.T) {
+	configured := map[string]string{
+		"music":   "♫",
+		"stars":   "★",
+		"★ music": "",
+		"music ★": "",
+	}
+	lookup := func(name string) (string, bool) {
+		icon, ok := configured[name]
+		return icon, ok
+	}
+	for _, template := range []string{
+		"{num}: {icon} {name}",
+		"{num} {name} {icon}",
+	} {
+		f, err := New(template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f = f.WithIcons(lookup)
+		for _, p := range []Parts{
+			{Num: 3, Name: "music", Icon: "♫"},
+			{Num: 3, Name: "stars", Icon: "★"},
+			{Num: 3, Name: "music", Icon: "♫", Color: "orange"},
+
+			// Glyphs which are part of the name of workspaces without icon
+			// (or of configured workspaces).
+			{Num: 4, Name: "– notes"},
+			{Num: 4, Name: "» inbox"},
+			{Num: 4, Name: "notes –"},
+			{Num: 4, Name: "★ music"},
+			{Num: 4, Name: "music ★"},
+			{Num: -1, Name: "» inbox"},
+			{Num: 4, Name: "» inbox", Color: "#f80"},
+		} {
+			name := f.Format(p)
+			if got := f.Parse(name); got != p {
+				t.Errorf("template %q: Parse(Format(%+v)) = %+v (name %q), want %+v", template, p, got, name, p)
+			}
+		}
+	}
+
+	// Without icon lookup, the glyph is parsed as the icon.
+	f, err := New(DefaultTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.Parse("4: – notes"), (Parts{Num: 4, Name: "notes", Icon: "–"}); got != want {
+		t.Errorf("Parse() = %+v, want %+v", got, want)
+	}
+	if got, want := f.WithIcons(lookup).Parse("4: – notes"), (Parts{Num: 4, Name: "– notes"}); got != want {
+		t.Errorf("WithIcons: Parse() = %+v, want %+v", got, want)
+	}
+}
+
+func TestValidIcon(t *testing.T) {
+	for _, tt := range []struct {
+		icon string
+		want bool
+	}{
+		{"♫", true},
+		{"\uf001", true}, // Font Awesome music
+		{"🎵", true},
+		{"★☆", true},
+		{"", false},
+		{"*", false},
+		{"!", false},
+		{"a", false},
+		{"♫ ♫", false},
+		{"♫1", false},
+		{"<", false},
+	} {
+		if got := ValidIcon(tt.icon); got != tt.want {
+			t.Errorf("ValidIcon(%q) = %v, want %v", tt.icon, got, tt.want)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, template := range []string{
+		"{name}",
+		"{num}{name}",
+		"{name}: {num}",
+		"{num}: {nmae}",
+		"{num}: {name",
+		"{num}: {name} {name}",
+		"{num}: {icon}",
+	} {
+		if _, err := New(template); err == nil {
+			t.Errorf("New(%q) unexpectedly succeeded", template)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// Package xwin reads properties of X11 windows.
+package xwin
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// PID returns the _NET_WM_PID property of the specified X11 window, i.e. the
+// process id of the process which created the window (if set by the client).
+func PID(xc *xgb.Conn, window int64) (int, error) {
+	const name = "_NET_WM_PID"
+	atom, err := xproto.InternAtom(xc, true, uint16(len(name)), name).Reply()
+	if err != nil {
+		return 0, err
+	}
+	reply, err := xproto.GetProperty(xc, false, xproto.Window(window), atom.Atom, xproto.AtomCardinal, 0, 1).Reply()
+	if err != nil {
+		return 0, err
+	}
+	if reply.Format != 32 || len(reply.Value) < 4 {
+		return 0, fmt.Errorf("%s not set on window %d", name, window)
+	}
+	return int(xgb.Get32(reply.Value)), nil
+}